@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+// isCloud reports whether the server is Atlassian Cloud, as opposed to Server
+// or Data Center. Cloud sites are usually recognizable from their name, but
+// otherwise the server is asked.
+func isCloud(ctx context.Context, u *url.URL) bool {
+	if strings.HasSuffix(u.Hostname(), ".atlassian.net") {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	ref, _ := url.Parse("rest/api/2/serverInfo")
+	base := *u
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.ResolveReference(ref).String(), nil)
+	if err != nil {
+		debug("serverInfo: %v", err)
+		return false
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		debug("serverInfo: %v", err)
+		return false
+	}
+	defer res.Body.Close()
+	var info struct {
+		DeploymentType string `json:"deploymentType"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		debug("serverInfo: %v", err)
+		return false
+	}
+	debug("deployment type: %q", info.DeploymentType)
+	return info.DeploymentType == "Cloud"
+}
+
+// authClient returns an http.Client that authenticates with the credentials in
+// the manner named by kind:
+//
+//	basic: username (the email address, for Cloud) and API token
+//	pat: Data Center personal access token; the username is ignored
+//	auto: basic for Cloud, pat otherwise
+func authClient(ctx context.Context, kind string, u *url.URL, user, pass string) (*http.Client, error) {
+	if kind == "auto" {
+		kind = "pat"
+		if isCloud(ctx, u) {
+			kind = "basic"
+		}
+	}
+	debug("using %s auth for %s", kind, u.Host)
+	switch kind {
+	case "basic":
+		if user == "" {
+			return nil, fmt.Errorf("basic auth needs a username (the account's email address for Cloud)")
+		}
+		t := jira.BasicAuthTransport{
+			Username: user,
+			Password: pass,
+		}
+		return t.Client(), nil
+	case "pat":
+		t := jira.PATAuthTransport{
+			Token: pass,
+		}
+		return t.Client(), nil
+	}
+	return nil, fmt.Errorf("unknown auth type %q", kind)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"9fans.net/go/acme"
+	jira "github.com/andygrunwald/go-jira"
+)
+
+// boardID resolves the board named in a window title. Boards can be named
+// by ID or by (a unique part of) their name.
+func (u *UI) boardID(name string) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	l, _, err := u.j.Board.GetAllBoards(&jira.BoardListOptions{Name: name})
+	if err != nil {
+		return 0, err
+	}
+	switch len(l.Values) {
+	case 0:
+		return 0, fmt.Errorf("no board matching %q", name)
+	case 1:
+		return l.Values[0].ID, nil
+	}
+	for _, b := range l.Values {
+		if b.Name == name {
+			return b.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("board name %q is ambiguous", name)
+}
+
+type backlog struct {
+	Board  string
+	Total  int
+	Issues []jira.Issue
+}
+
+func (u *UI) fetchBacklog(w *win) {
+	id, err := u.boardID(strings.TrimPrefix(w.Title, "backlog/"))
+	if err != nil {
+		u.err(err.Error())
+		return
+	}
+	b, _, err := u.j.Board.GetBoard(id)
+	if err != nil {
+		u.err(err.Error())
+		return
+	}
+
+	bl := backlog{Board: b.Name}
+	for {
+		req, err := u.j.NewRequest("GET", fmt.Sprintf("/rest/agile/1.0/board/%d/backlog?fields=issuetype,status,summary&startAt=%d", id, len(bl.Issues)), nil)
+		if err != nil {
+			u.err(err.Error())
+			return
+		}
+		var page struct {
+			Total  int          `json:"total"`
+			Issues []jira.Issue `json:"issues"`
+		}
+		if _, err := u.j.Do(req, &page); err != nil {
+			u.err(err.Error())
+			return
+		}
+		bl.Total = page.Total
+		bl.Issues = append(bl.Issues, page.Issues...)
+		if len(page.Issues) == 0 || len(bl.Issues) >= page.Total {
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := u.tmpls.ExecuteTemplate(&buf, "backlog", &bl); err != nil {
+		u.err(err.Error())
+		return
+	}
+	w.rank = w.rank[:0]
+	for _, i := range bl.Issues {
+		w.rank = append(w.rank, i.Key)
+	}
+
+	w.Clear()
+	w.Write("data", buf.Bytes())
+	w.Ctl("clean")
+	w.Addr("0")
+	w.Ctl("dot=addr")
+	w.Ctl("show")
+}
+
+// rankCmd handles the ranking commands of a backlog window:
+//
+//	Rank [KEY] before|after OTHER
+//	Top [KEY]
+//	Bottom [KEY]
+//
+// If KEY is omitted, the issue under the cursor is used.
+func (u *UI) rankCmd(w *win, cmd string, e *acme.Event) bool {
+	args := strings.Fields(cmd)
+	if len(args) == 0 {
+		return false
+	}
+	args = append(args, strings.Fields(string(e.Arg))...)
+	var key, before, after string
+	switch args[0] {
+	case "Top", "Bottom":
+		if len(args) > 1 {
+			key = args[1]
+		}
+		if len(w.rank) == 0 {
+			u.err("empty backlog")
+			return true
+		}
+		if args[0] == "Top" {
+			before = w.rank[0]
+		} else {
+			after = w.rank[len(w.rank)-1]
+		}
+	case "Rank":
+		args = args[1:]
+		if len(args) == 3 {
+			key, args = args[0], args[1:]
+		}
+		if len(args) != 2 {
+			u.err("usage: Rank [KEY] before|after OTHER")
+			return true
+		}
+		switch args[0] {
+		case "before":
+			before = args[1]
+		case "after":
+			after = args[1]
+		default:
+			u.err(fmt.Sprintf("Rank: unknown position %q", args[0]))
+			return true
+		}
+	default:
+		return false
+	}
+	if key == "" {
+		key = w.dotKey(u.projRe)
+	}
+	if key == "" {
+		u.err("no issue selected")
+		return true
+	}
+	if key == before || key == after {
+		return true
+	}
+	debug("rank: %q before %q after %q", key, before, after)
+	if err := u.rankIssues([]string{key}, before, after); err != nil {
+		u.err(err.Error())
+	}
+	w.Reload()
+	return true
+}
+
+// rankIssues moves the issues to just before or after the named issue.
+func (u *UI) rankIssues(keys []string, before, after string) error {
+	body := struct {
+		Issues []string `json:"issues"`
+		Before string   `json:"rankBeforeIssue,omitempty"`
+		After  string   `json:"rankAfterIssue,omitempty"`
+	}{
+		Issues: keys,
+		Before: before,
+		After:  after,
+	}
+	req, err := u.j.NewRequest("PUT", "/rest/agile/1.0/issue/rank", &body)
+	if err != nil {
+		return err
+	}
+	res, err := u.j.Do(req, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// A partial failure is reported per-issue.
+	if res.StatusCode != http.StatusMultiStatus {
+		return nil
+	}
+	var multi struct {
+		Entries []struct {
+			IssueKey string   `json:"issueKey"`
+			Errors   []string `json:"errors"`
+		} `json:"entries"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&multi); err != nil {
+		return err
+	}
+	var msg []string
+	for _, e := range multi.Entries {
+		for _, m := range e.Errors {
+			msg = append(msg, e.IssueKey+": "+m)
+		}
+	}
+	if len(msg) != 0 {
+		return fmt.Errorf("rank: %s", strings.Join(msg, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"9fans.net/go/acme"
+)
+
+// JqlValueCtx matches the end of some JQL that is waiting for a value: a
+// field name and an operator.
+var jqlValueCtx = regexp.MustCompile(`(?i)("[^"]+"|[\w.\[\]]+)\s*(!=|!~|>=|<=|=|~|>|<|\s(?:not\s+)?in\s*\((?:[^()]*,)?|\sis(?:\s+not)?|\swas(?:\s+not)?(?:\s+in)?)\s*$`)
+
+// valueField reports the field whose value is being written at the end of
+// the JQL before, if any.
+func valueField(before string) (string, bool) {
+	m := jqlValueCtx.FindStringSubmatch(before)
+	if m == nil {
+		return "", false
+	}
+	return strings.Trim(m[1], `"`), true
+}
+
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune("=!<>~(),", r)
+}
+
+// wordAt returns the span of the word around the rune offset q in s.
+func wordAt(s []rune, q int) (q0, q1 int) {
+	for q0 = q; q0 > 0 && isWordRune(s[q0-1]); q0-- {
+	}
+	for q1 = q; q1 < len(s) && isWordRune(s[q1]); q1++ {
+	}
+	return q0, q1
+}
+
+type jqlCandidate struct {
+	Value       string `json:"value"`
+	DisplayName string `json:"displayName"`
+}
+
+// complete looks up the possible completions of the word at the cursor in a
+// search window's query, and offers them in the +Complete window.
+func (u *UI) complete(w *win) {
+	w.Ctl("addr=dot")
+	q0, q1, err := w.ReadAddr()
+	if err != nil {
+		u.err(err.Error())
+		return
+	}
+	w.Addr("1")
+	b, err := w.ReadAll("xdata")
+	if err != nil {
+		u.err(err.Error())
+		return
+	}
+	line := []rune(strings.TrimSuffix(string(b), "\n"))
+	if q1 > len(line) {
+		u.err("Complete: cursor is not in the query")
+		return
+	}
+	if q0 == q1 {
+		q0, q1 = wordAt(line, q0)
+	}
+	word := string(line[q0:q1])
+	prefix := strings.TrimLeft(word, `"`)
+	_, before := parseSearch(string(line[:q0]))
+	debug("completing %q after %q", word, before)
+
+	var cs []string
+	field, isValue := valueField(" " + before)
+	if isValue {
+		cs, err = u.completeValue(field, prefix)
+	} else {
+		cs, err = u.completeField(prefix)
+	}
+	if err != nil {
+		u.err(err.Error())
+		return
+	}
+	if len(cs) == 0 {
+		u.err(fmt.Sprintf("Complete: nothing for %q", word))
+		return
+	}
+
+	c := u.show("+Complete")
+	if c == nil {
+		c = u.new("+Complete")
+		if c == nil {
+			return
+		}
+	}
+	c.Clear()
+	c.Write("data", []byte(strings.Join(cs, "\n")+"\n"))
+	c.Ctl("clean")
+	c.Addr("0")
+	c.Ctl("dot=addr")
+	c.Ctl("show")
+	c.look = func(c *win, e *acme.Event) bool {
+		if e.C2 != 'L' {
+			return false
+		}
+		b, err := c.ReadAll("body")
+		if err != nil {
+			u.err(err.Error())
+			return true
+		}
+		choice := strings.TrimSpace(lineAt(b, e.Q0))
+		if choice == "" {
+			return true
+		}
+		w.Addr("#%d,#%d", q0, q1)
+		if cur, err := w.ReadAll("xdata"); err != nil || string(cur) != word {
+			u.err("Complete: query changed since completing")
+			return true
+		}
+		w.Addr("#%d,#%d", q0, q1)
+		w.Write("data", []byte(choice))
+		w.Addr("#%d", q0+len([]rune(choice)))
+		w.Ctl("dot=addr")
+		w.Ctl("show")
+		c.Del(true)
+		return true
+	}
+}
+
+type jqlData struct {
+	Fields    []jqlCandidate `json:"visibleFieldNames"`
+	Functions []jqlCandidate `json:"visibleFunctionNames"`
+}
+
+func (u *UI) autocompleteData() (*jqlData, error) {
+	req, err := u.j.NewRequest("GET", "/rest/api/2/jql/autocompletedata", nil)
+	if err != nil {
+		return nil, err
+	}
+	var res jqlData
+	if _, err := u.j.Do(req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// completeField returns the field names starting with prefix.
+func (u *UI) completeField(prefix string) ([]string, error) {
+	d, err := u.autocompleteData()
+	if err != nil {
+		return nil, err
+	}
+	var r []string
+	seen := make(map[string]bool)
+	for _, f := range d.Fields {
+		v := f.Value
+		if seen[v] || !(hasPrefixFold(strings.Trim(v, `"`), prefix) || hasPrefixFold(f.DisplayName, prefix)) {
+			continue
+		}
+		seen[v] = true
+		r = append(r, v)
+	}
+	return r, nil
+}
+
+// completeValue returns the values of field starting with prefix, along with
+// any functions that could stand in for one.
+func (u *UI) completeValue(field, prefix string) ([]string, error) {
+	v := url.Values{}
+	v.Set("fieldName", field)
+	v.Set("fieldValue", prefix)
+	req, err := u.j.NewRequest("GET", "/rest/api/2/jql/autocompletedata/suggestions?"+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Results []jqlCandidate `json:"results"`
+	}
+	if _, err := u.j.Do(req, &res); err != nil {
+		return nil, err
+	}
+	var r []string
+	for _, c := range res.Results {
+		s := c.Value
+		if strings.IndexFunc(s, unicode.IsSpace) != -1 && !strings.HasPrefix(s, `"`) {
+			s = `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+		}
+		r = append(r, s)
+	}
+	d, err := u.autocompleteData()
+	if err != nil {
+		// The suggestions are still useful on their own.
+		log.Println(err)
+		return r, nil
+	}
+	for _, f := range d.Functions {
+		if hasPrefixFold(f.Value, prefix) {
+			r = append(r, f.Value)
+		}
+	}
+	return r, nil
+}
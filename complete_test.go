@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestValueField(t *testing.T) {
+	for _, tc := range []struct {
+		Before string
+		Field  string
+		OK     bool
+	}{
+		{Before: "project = ", Field: "project", OK: true},
+		{Before: "project=", Field: "project", OK: true},
+		{Before: "status in (Open, ", Field: "status", OK: true},
+		{Before: "assignee was not ", Field: "assignee", OK: true},
+		{Before: `"Story Points" >= `, Field: "Story Points", OK: true},
+		{Before: "project = FOO AND ", OK: false},
+		{Before: "", OK: false},
+	} {
+		f, ok := valueField(" " + tc.Before)
+		if ok != tc.OK || f != tc.Field {
+			t.Errorf("%q: got %q, %v; want %q, %v", tc.Before, f, ok, tc.Field, tc.OK)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config is the contents of the configuration file. It looks like:
+//
+//	# Defaults for every server.
+//	wrap 100
+//	fields status,assignee,summary
+//	query stale updated < -30d AND assignee = currentUser()
+//	credential os,netrc
+//	templates ~/lib/jira
+//
+//	# Every server is connected; the first is the default.
+//	server https://corp.atlassian.net
+//		auth basic
+//		credential pass:work/jira
+//		field Story Points customfield_10016
+//		query team project = ABC AND resolution is empty
+//
+// Lines indented under a server only apply to it. The global settings are
+// the same as the flags of the same meaning, which override them.
+type config struct {
+	Wrap       string
+	Fields     string
+	Auth       string
+	Credential string
+	Templates  string
+	Queries    map[string]string
+	Servers    []*serverConfig
+}
+
+type serverConfig struct {
+	URL        *url.URL
+	Auth       string
+	Credential string
+	// Fields maps names to field IDs, for fields the server doesn't name
+	// usefully.
+	Fields  map[string]string
+	Queries map[string]string
+}
+
+// configFile returns the name of the configuration file.
+func configFile() (string, error) {
+	d, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "jira", "config"), nil
+}
+
+// loadConfig reads the configuration file, if there is one.
+func loadConfig() (*config, error) {
+	fn, err := configFile()
+	if err != nil {
+		return &config{}, nil
+	}
+	b, err := os.ReadFile(fn)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return &config{}, nil
+	case err != nil:
+		return nil, err
+	}
+	c, err := parseConfig(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", fn, err)
+	}
+	return c, nil
+}
+
+func parseConfig(b []byte) (*config, error) {
+	c := &config{Queries: make(map[string]string)}
+	var srv *serverConfig
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		l := s.Text()
+		indented := strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		key, val := cutSpace(l)
+		if val == "" {
+			return nil, fmt.Errorf("%d: %q needs a value", n, key)
+		}
+		if !indented {
+			srv = nil
+		}
+		if indented && srv == nil {
+			return nil, fmt.Errorf("%d: indented line not under a server", n)
+		}
+		var err error
+		switch {
+		case key == "server" && !indented:
+			u, perr := url.Parse(val)
+			if perr != nil {
+				err = perr
+				break
+			}
+			if !isServer(u) {
+				err = fmt.Errorf("server %q is not an http or https URL", val)
+				break
+			}
+			srv = &serverConfig{
+				URL:     u,
+				Fields:  make(map[string]string),
+				Queries: make(map[string]string),
+			}
+			c.Servers = append(c.Servers, srv)
+		case key == "query":
+			name, q := cutSpace(val)
+			if q == "" || !homeName.MatchString(name+":") {
+				err = errors.New("query needs a name and some JQL")
+				break
+			}
+			if err = checkHomeName(name); err != nil {
+				break
+			}
+			if srv != nil {
+				srv.Queries[name] = q
+			} else {
+				c.Queries[name] = q
+			}
+		case key == "auth" && srv != nil:
+			srv.Auth = val
+		case key == "credential" && srv != nil:
+			if _, err = parseCreds(val); err == nil {
+				srv.Credential = val
+			}
+		case key == "field" && srv != nil:
+			i := strings.LastIndexAny(val, " \t")
+			if i == -1 {
+				err = errors.New("field needs a name and an ID")
+				break
+			}
+			srv.Fields[strings.TrimSpace(val[:i])] = val[i+1:]
+		case key == "wrap" && srv == nil:
+			c.Wrap = val
+		case key == "fields" && srv == nil:
+			c.Fields = val
+		case key == "auth" && srv == nil:
+			c.Auth = val
+		case key == "credential" && srv == nil:
+			if _, err = parseCreds(val); err == nil {
+				c.Credential = val
+			}
+		case key == "templates" && srv == nil:
+			c.Templates = val
+		default:
+			err = fmt.Errorf("unknown setting %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%d: %w", n, err)
+		}
+	}
+	return c, s.Err()
+}
+
+// setFlags sets the flags from the configuration. This happens before the
+// command line is parsed, so the command line wins.
+func (c *config) setFlags(fs *flag.FlagSet) error {
+	set := func(name, v string) error {
+		if v == "" {
+			return nil
+		}
+		if err := fs.Set(name, v); err != nil {
+			return fmt.Errorf("config: %s: %w", name, err)
+		}
+		return nil
+	}
+	for _, s := range [][2]string{{"w", c.Wrap}, {"f", c.Fields}, {"T", expandHome(c.Templates)}} {
+		if err := set(s[0], s[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// creds returns the credential sources from the file, keyed the same as the
+// flag's. They're kept out of the flag so that any sources given on the
+// command line win, even over a server's.
+func (c *config) creds() credFlag {
+	m := credFlag{}
+	if c.Credential != "" {
+		m[""] = c.Credential
+	}
+	for _, s := range c.Servers {
+		if s.Credential != "" {
+			m[s.URL.Host] = s.Credential
+		}
+	}
+	return m
+}
+
+// server returns the configuration for the server, if there is any.
+func (c *config) server(u *url.URL) *serverConfig {
+	for _, s := range c.Servers {
+		if s.URL.Host == u.Host {
+			return s
+		}
+	}
+	return nil
+}
+
+// queries returns the home queries for the server from the file, with the
+// command line's, cmd, overriding them.
+func (c *config) queries(u *url.URL, cmd map[string]string) map[string]string {
+	r := make(map[string]string)
+	for n, q := range c.Queries {
+		r[n] = q
+	}
+	if s := c.server(u); s != nil {
+		for n, q := range s.Queries {
+			r[n] = q
+		}
+	}
+	for n, q := range cmd {
+		r[n] = q
+	}
+	return r
+}
+
+// authType returns the kind of auth configured for the server, if any. This
+// isn't done with the flag, as a server's setting should win over the global
+// one but not the command line.
+func (c *config) authType(u *url.URL) string {
+	if s := c.server(u); s != nil && s.Auth != "" {
+		return s.Auth
+	}
+	return c.Auth
+}
+
+func isServer(u *url.URL) bool {
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// cutSpace splits s at the first run of spaces.
+func cutSpace(s string) (string, string) {
+	i := strings.IndexAny(s, " \t")
+	if i == -1 {
+		return s, ""
+	}
+	return s[:i], strings.TrimSpace(s[i:])
+}
+
+// expandHome expands a leading "~" to the user's home directory.
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, p[1:])
+}
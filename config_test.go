@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"net/url"
+	"testing"
+)
+
+const testConfig = `# Defaults.
+wrap 100
+fields status, summary
+query stale updated < -30d
+auth pat
+
+server https://corp.atlassian.net
+	auth basic
+	credential pass:work/jira
+	field Story Points customfield_10016
+	query team project = ABC
+
+server https://jira.corp.com:8443
+credential os,netrc
+`
+
+func TestParseConfig(t *testing.T) {
+	c, err := parseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Wrap != "100" || c.Fields != "status, summary" || c.Credential != "os,netrc" {
+		t.Errorf("got globals %q, %q, %q", c.Wrap, c.Fields, c.Credential)
+	}
+	if got := c.Queries["stale"]; got != "updated < -30d" {
+		t.Errorf("got query %q", got)
+	}
+	if len(c.Servers) != 2 {
+		t.Fatalf("got %d servers, want 2", len(c.Servers))
+	}
+	s := c.Servers[0]
+	if s.URL.Host != "corp.atlassian.net" || s.Credential != "pass:work/jira" {
+		t.Errorf("got server %v, %q", s.URL, s.Credential)
+	}
+	if got := s.Fields["Story Points"]; got != "customfield_10016" {
+		t.Errorf("got field %q", got)
+	}
+	if got := s.Queries["team"]; got != "project = ABC" {
+		t.Errorf("got server query %q", got)
+	}
+	for _, tc := range []struct{ url, auth string }{
+		{"https://corp.atlassian.net", "basic"},
+		{"https://jira.corp.com:8443", "pat"},
+		{"https://other.corp.com", "pat"},
+	} {
+		u, _ := url.Parse(tc.url)
+		if got := c.authType(u); got != tc.auth {
+			t.Errorf("%s: got auth %q, want %q", tc.url, got, tc.auth)
+		}
+	}
+
+	for _, bad := range []string{
+		"\tauth basic\n",
+		"server corp.atlassian.net\n",
+		"server https://a.example.com\n\tcolor blue\n",
+		"query stale\n",
+		"query search project = ABC\n",
+		"server https://a.example.com\n\tquery filters project = ABC\n",
+		"wrap\n",
+	} {
+		if _, err := parseConfig([]byte(bad)); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestConfigFlags(t *testing.T) {
+	c, err := parseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	wrap := fs.Int("w", 80, "")
+	fields := fs.String("f", "", "")
+	queries := queryFlag{}
+	fs.Var(queries, "q", "")
+
+	if err := c.setFlags(fs); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-w", "120", "-q", "stale:updated < -7d", "-q", "team:project = XYZ"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := queries.Set("backlog: project = XYZ"); err == nil {
+		t.Error("expected error for a built-in window's name")
+	}
+	if *wrap != 120 {
+		t.Errorf("got wrap %d, want the flag's 120", *wrap)
+	}
+	if *fields != "status, summary" {
+		t.Errorf("got fields %q", *fields)
+	}
+	// The command line wins over the server's queries, which win over the
+	// global ones.
+	corp, _ := url.Parse("https://corp.atlassian.net")
+	qs := c.queries(corp, queries)
+	if got := qs["stale"]; got != "updated < -7d" {
+		t.Errorf("got query %q, want the flag's", got)
+	}
+	if got := qs["team"]; got != "project = XYZ" {
+		t.Errorf("got query %q, want the flag's", got)
+	}
+	if got := c.queries(corp, nil)["team"]; got != "project = ABC" {
+		t.Errorf("got query %q, want the server's", got)
+	}
+
+	creds := c.creds()
+	if creds[""] != "os,netrc" || creds["corp.atlassian.net"] != "pass:work/jira" {
+		t.Errorf("got credentials %v", creds)
+	}
+	defer func(cmd, file credFlag) { credSources, fileCreds = cmd, file }(credSources, fileCreds)
+	credSources, fileCreds = credFlag{}, creds
+	if got := credSpec("corp.atlassian.net"); got != "pass:work/jira" {
+		t.Errorf("got credentials %q, want the server's", got)
+	}
+	if got := credSpec("other.example.com"); got != "os,netrc" {
+		t.Errorf("got credentials %q, want the global ones", got)
+	}
+	// Any sources on the command line win over the file's.
+	credSources.Set("netrc")
+	if got := credSpec("corp.atlassian.net"); got != "netrc" {
+		t.Errorf("got credentials %q, want the flag's", got)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultCreds is the order credential sources are tried in, if not
+// configured otherwise.
+const defaultCreds = "os,file,netrc"
+
+// A credSource is somewhere credentials can be found.
+type credSource struct {
+	Name   string
+	Lookup func(ctx context.Context, host string) (user, pass string, err error)
+}
+
+var errNoCreds = errors.New("no credentials found")
+
+// CredFlag holds the credential sources to use, keyed by host. The sources
+// for any host not listed are under "".
+type credFlag map[string]string
+
+var credHost = regexp.MustCompile(`^([A-Za-z0-9.-]+(?::[0-9]+)?)=(.*)$`)
+
+func (c credFlag) String() string {
+	return c[""]
+}
+
+func (c credFlag) Set(s string) error {
+	host, spec := "", s
+	if m := credHost.FindStringSubmatch(s); m != nil {
+		host, spec = m[1], m[2]
+	}
+	if _, err := parseCreds(spec); err != nil {
+		return err
+	}
+	c[host] = spec
+	return nil
+}
+
+var credSources = credFlag{}
+
+// FileCreds are the credential sources from the configuration file. They only
+// count for a host the command line doesn't give any sources for.
+var fileCreds = credFlag{}
+
+// lookup returns the sources for the host, or for any host.
+func (c credFlag) lookup(host string) (string, bool) {
+	if spec, ok := c[host]; ok {
+		return spec, true
+	}
+	spec, ok := c[""]
+	return spec, ok
+}
+
+// credSpec returns the credential sources to try for the host.
+func credSpec(host string) string {
+	if spec, ok := credSources.lookup(host); ok {
+		return spec
+	}
+	if spec, ok := fileCreds.lookup(host); ok {
+		return spec
+	}
+	return defaultCreds
+}
+
+// parseCreds parses a comma-separated list of credential sources:
+//
+//	os: the OS secret store
+//	file: ~/.jira-creds, see secretsFile
+//	netrc: ~/.netrc, or the file named by $NETRC
+//	pass[:ENTRY]: the pass password store, in entry "jira/HOST" by default
+//	gopass[:ENTRY]: the same, using gopass
+//	command:CMD: a git-style credential helper
+//
+// As the command can contain anything, it runs to the end of the list.
+func parseCreds(spec string) ([]credSource, error) {
+	var r []credSource
+	for spec != "" {
+		var s string
+		if strings.HasPrefix(spec, "command:") {
+			s, spec = spec, ""
+		} else {
+			s, spec, _ = strings.Cut(spec, ",")
+		}
+		s = strings.TrimSpace(s)
+		kind, arg, _ := strings.Cut(s, ":")
+		switch kind {
+		case "":
+			continue
+		case "os":
+			r = append(r, credSource{Name: s, Lookup: secretsOS})
+		case "file":
+			r = append(r, credSource{Name: s, Lookup: func(_ context.Context, host string) (string, string, error) {
+				return secretsFile(host)
+			}})
+		case "netrc":
+			r = append(r, credSource{Name: s, Lookup: netrcCreds})
+		case "pass", "gopass":
+			r = append(r, credSource{Name: s, Lookup: func(ctx context.Context, host string) (string, string, error) {
+				return passCreds(ctx, kind, arg, host)
+			}})
+		case "command":
+			if arg == "" {
+				return nil, errors.New("credential command missing")
+			}
+			r = append(r, credSource{Name: s, Lookup: func(ctx context.Context, host string) (string, string, error) {
+				return commandCreds(ctx, arg, host)
+			}})
+		default:
+			return nil, fmt.Errorf("unknown credential source %q", kind)
+		}
+	}
+	return r, nil
+}
+
+// credentials tries the credential sources configured for host in turn,
+// returning the first credentials found.
+func credentials(ctx context.Context, host string) (string, string, error) {
+	srcs, err := parseCreds(credSpec(host))
+	if err != nil {
+		return "", "", err
+	}
+	for _, s := range srcs {
+		user, pass, err := s.Lookup(ctx, host)
+		if err == nil && pass == "" {
+			err = errNoCreds
+		}
+		if err != nil {
+			debug("credentials for %s: %s: %v", host, s.Name, err)
+			continue
+		}
+		debug("credentials for %s: using %s", host, s.Name)
+		return user, pass, nil
+	}
+	return "", "", fmt.Errorf("%s: %w", host, errNoCreds)
+}
+
+// netrcCreds looks for the host in the user's netrc file.
+func netrcCreds(_ context.Context, host string) (string, string, error) {
+	fn := os.Getenv("NETRC")
+	if fn == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", err
+		}
+		fn = filepath.Join(home, ".netrc")
+	}
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return "", "", err
+	}
+	user, pass, ok := parseNetrc(b, host)
+	if !ok {
+		return "", "", errNoCreds
+	}
+	return user, pass, nil
+}
+
+// parseNetrc returns the login and password for the host from the netrc
+// file b. An entry for the host with its port is preferred, then one for the
+// bare hostname, then the default entry.
+func parseNetrc(b []byte, host string) (string, string, bool) {
+	type entry struct{ login, password string }
+	entries := make(map[string]*entry)
+	var cur, def *entry
+	var key string // the keyword waiting for its value
+	macro := false
+	s := bufio.NewScanner(bytes.NewReader(b))
+Line:
+	for s.Scan() {
+		if macro {
+			// Macro definitions run to the next blank line.
+			macro = strings.TrimSpace(s.Text()) != ""
+			continue
+		}
+		for _, t := range strings.Fields(s.Text()) {
+			if key != "" {
+				switch key {
+				case "machine":
+					cur = &entry{}
+					if entries[t] == nil {
+						entries[t] = cur
+					}
+				case "login":
+					if cur != nil {
+						cur.login = t
+					}
+				case "password":
+					if cur != nil {
+						cur.password = t
+					}
+				}
+				key = ""
+				continue
+			}
+			switch t {
+			case "machine", "login", "password", "account":
+				key = t
+			case "default":
+				cur = &entry{}
+				def = cur
+			case "macdef":
+				// The name is the rest of the line, and the definition
+				// starts on the next.
+				cur, macro = nil, true
+				continue Line
+			}
+		}
+	}
+	name := host
+	if h, _, ok := strings.Cut(host, ":"); ok {
+		name = h
+	}
+	for _, e := range []*entry{entries[host], entries[name], def} {
+		if e != nil {
+			return e.login, e.password, true
+		}
+	}
+	return "", "", false
+}
+
+// passCreds runs pass (or gopass) to show the entry, "jira/HOST" if not
+// given. The first line is the token, and the username comes from a "login:",
+// "user:", or "username:" line, following the usual pass conventions.
+func passCreds(ctx context.Context, prog, entry, host string) (string, string, error) {
+	if entry == "" {
+		entry = "jira/" + host
+	}
+	out, err := exec.CommandContext(ctx, prog, "show", entry).Output()
+	if err != nil {
+		return "", "", fmt.Errorf("%s show %s: %w", prog, entry, err)
+	}
+	user, pass := parsePass(out)
+	return user, pass, nil
+}
+
+func parsePass(b []byte) (user, pass string) {
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 0; s.Scan(); n++ {
+		if n == 0 {
+			pass = s.Text()
+			continue
+		}
+		k, v, ok := strings.Cut(s.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "login", "user", "username":
+			user = strings.TrimSpace(v)
+		}
+	}
+	return user, pass
+}
+
+// commandCreds asks a credential helper, in the manner of git: the command
+// is run by the shell with "get" appended, and the helper is given the
+// protocol and host on stdin and answers with "username=" and "password="
+// lines. So "git credential-store" or "git credential-libsecret" can be used
+// as-is.
+func commandCreds(ctx context.Context, command, host string) (string, string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command+" get")
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=" + host + "\n\n")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", command, err)
+	}
+	user, pass := parseHelper(out)
+	return user, pass, nil
+}
+
+func parseHelper(b []byte) (user, pass string) {
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		k, v, _ := strings.Cut(s.Text(), "=")
+		switch k {
+		case "username":
+			user = v
+		case "password":
+			pass = v
+		}
+	}
+	return user, pass
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseNetrc(t *testing.T) {
+	const netrc = `machine jira.example.com login alice password one
+machine jira.example.com:8443
+	login bob
+	password two
+default login carol password three
+`
+	tt := []struct {
+		host, user, pass string
+	}{
+		{"jira.example.com", "alice", "one"},
+		{"jira.example.com:8443", "bob", "two"},
+		{"jira.example.com:9000", "alice", "one"},
+		{"other.example.com", "carol", "three"},
+	}
+	for _, tc := range tt {
+		user, pass, ok := parseNetrc([]byte(netrc), tc.host)
+		if !ok || user != tc.user || pass != tc.pass {
+			t.Errorf("%s: got %q, %q, %v; want %q, %q", tc.host, user, pass, ok, tc.user, tc.pass)
+		}
+	}
+	if _, _, ok := parseNetrc([]byte("machine a login b password c\n"), "d"); ok {
+		t.Error("found credentials for a missing host")
+	}
+
+	// Entries can follow a macro, after the blank line ending it.
+	const macro = `machine a login b password c
+macdef init
+	machine d login e password f
+
+machine d login g password h
+`
+	if user, pass, ok := parseNetrc([]byte(macro), "d"); !ok || user != "g" || pass != "h" {
+		t.Errorf("after macdef: got %q, %q, %v; want %q, %q", user, pass, ok, "g", "h")
+	}
+}
+
+func TestParseCreds(t *testing.T) {
+	tt := []struct {
+		spec  string
+		names []string
+	}{
+		{defaultCreds, []string{"os", "file", "netrc"}},
+		{"pass, gopass:work/jira", []string{"pass", "gopass:work/jira"}},
+		{"netrc,command:helper --opt=a,b", []string{"netrc", "command:helper --opt=a,b"}},
+	}
+	for _, tc := range tt {
+		srcs, err := parseCreds(tc.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tc.spec, err)
+			continue
+		}
+		var names []string
+		for _, s := range srcs {
+			names = append(names, s.Name)
+		}
+		if len(names) != len(tc.names) {
+			t.Errorf("%q: got %q, want %q", tc.spec, names, tc.names)
+			continue
+		}
+		for i := range names {
+			if names[i] != tc.names[i] {
+				t.Errorf("%q: got %q, want %q", tc.spec, names, tc.names)
+				break
+			}
+		}
+	}
+	for _, spec := range []string{"os,keyring", "command:"} {
+		if _, err := parseCreds(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestCredFlag(t *testing.T) {
+	c := credFlag{}
+	for _, s := range []string{"netrc", "jira.example.com:8443=pass,os", "command:x=y"} {
+		if err := c.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[string]string{
+		"":                      "command:x=y",
+		"jira.example.com:8443": "pass,os",
+	}
+	if len(c) != len(want) {
+		t.Errorf("got %v, want %v", c, want)
+	}
+	for k, v := range want {
+		if c[k] != v {
+			t.Errorf("%q: got %q, want %q", k, c[k], v)
+		}
+	}
+}
+
+func TestParseHelpers(t *testing.T) {
+	user, pass := parsePass([]byte("s3cret\nurl: https://jira.example.com\nlogin: alice@example.com\n"))
+	if user != "alice@example.com" || pass != "s3cret" {
+		t.Errorf("pass: got %q, %q", user, pass)
+	}
+	user, pass = parseHelper([]byte("protocol=https\nhost=jira.example.com\nusername=alice\npassword=a=b\n"))
+	if user != "alice" || pass != "a=b" {
+		t.Errorf("helper: got %q, %q", user, pass)
+	}
+}
+
+func TestParseCredsFile(t *testing.T) {
+	const file = `# Cloud
+corp.atlassian.net alice@corp.com:cloudtoken
+
+jira.corp.com alice:dctoken
+jira.corp.com:8443 alice:testtoken
+`
+	tt := []struct {
+		file, host, user, pass string
+		ok                     bool
+	}{
+		{file, "corp.atlassian.net", "alice@corp.com", "cloudtoken", true},
+		{file, "jira.corp.com", "alice", "dctoken", true},
+		{file, "jira.corp.com:8443", "alice", "testtoken", true},
+		{file, "jira.corp.com:9000", "alice", "dctoken", true},
+		{file, "other.atlassian.net", "", "", false},
+		{"alice:token\n", "anywhere.example.com", "alice", "token", true},
+	}
+	for _, tc := range tt {
+		user, pass, ok := parseCredsFile([]byte(tc.file), tc.host)
+		if ok != tc.ok || user != tc.user || pass != tc.pass {
+			t.Errorf("%s: got %q, %q, %v; want %q, %q, %v", tc.host, user, pass, ok, tc.user, tc.pass, tc.ok)
+		}
+	}
+}
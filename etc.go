@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"io"
 	"log"
+	"regexp"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -119,35 +120,87 @@ func wrap(t, prefix string) string {
 	var out strings.Builder
 	s := bufio.NewScanner(strings.NewReader(strings.TrimSpace(t)))
 	for s.Scan() {
-		out.WriteString(prefix)
 		line := s.Bytes()
 		// try to handle code blocks nicely
 		if bytes.HasPrefix(line, codestart) || bytes.HasSuffix(line, codeend) {
 			raw = !raw
 		}
-		if !raw {
-			line = bytes.TrimSpace(line)
-			for len(line) > max {
-				i := bytes.LastIndexFunc(line[:max], unicode.IsSpace)
-				if i < 0 {
-					// Managed to construct a run of text longer than our wrap,
-					// so just grab the first space.
-					i = bytes.IndexFunc(line, unicode.IsSpace)
-					if i < 0 {
-						break
-					}
-				}
-				out.Write(line[:i])
-				out.WriteByte('\n')
-				out.WriteString(prefix)
-				_, sz := utf8.DecodeRune(line[i:])
-				line = line[i+sz:] // skip the space
+		if raw {
+			out.WriteString(prefix)
+			out.Write(line)
+			out.WriteByte('\n')
+			continue
+		}
+		wrapLine(&out, bytes.TrimSpace(line), prefix, "", max)
+	}
+	return out.String()
+}
+
+// wrapLine writes line to out after the prefix, broken into lines of at most
+// max bytes. Lines after the first also get the indent, which counts against
+// max.
+func wrapLine(out *strings.Builder, line []byte, prefix, indent string, max int) {
+	out.WriteString(prefix)
+	n := max
+	for len(line) > n {
+		i := bytes.LastIndexFunc(line[:n], unicode.IsSpace)
+		if i < 0 {
+			// Managed to construct a run of text longer than our wrap,
+			// so just grab the first space.
+			i = bytes.IndexFunc(line, unicode.IsSpace)
+			if i < 0 {
+				break
 			}
 		}
-		out.Write(line)
+		out.Write(line[:i])
 		out.WriteByte('\n')
+		out.WriteString(prefix)
+		out.WriteString(indent)
+		_, sz := utf8.DecodeRune(line[i:])
+		line = line[i+sz:] // skip the space
+		if n = max - len(indent); n < max/2 {
+			n = max / 2
+		}
 	}
-	return out.String()
+	out.Write(line)
+	out.WriteByte('\n')
 }
 
 var jqlSan = strings.NewReplacer("\n\t", " ", "\n", " ", "\t", " ")
+
+// KeyAt reports the issue key that the text at rune offset q0 belongs to: the
+// closest line at or above q0 that starts with something matching re.
+func keyAt(b []byte, q0 int, re *regexp.Regexp) string {
+	i := byteOffset(b, q0)
+	for {
+		start := bytes.LastIndexByte(b[:i], '\n') + 1
+		if k := re.Find(b[start:]); k != nil {
+			return string(k)
+		}
+		if start == 0 {
+			return ""
+		}
+		i = start - 1
+	}
+}
+
+// LineAt returns the line containing the rune offset q0, without the newline.
+func lineAt(b []byte, q0 int) string {
+	i := byteOffset(b, q0)
+	start := bytes.LastIndexByte(b[:i], '\n') + 1
+	end := bytes.IndexByte(b[i:], '\n')
+	if end < 0 {
+		return string(b[start:])
+	}
+	return string(b[start : i+end])
+}
+
+// byteOffset converts the rune offset q0 into b to a byte offset.
+func byteOffset(b []byte, q0 int) int {
+	i := 0
+	for n := 0; n < q0 && i < len(b); n++ {
+		_, sz := utf8.DecodeRune(b[i:])
+		i += sz
+	}
+	return i
+}
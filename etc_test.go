@@ -1,6 +1,9 @@
 package main
 
-import "testing"
+import (
+	"regexp"
+	"testing"
+)
 
 var (
 	quotes = []struct {
@@ -72,3 +75,22 @@ func TestDiffStrings(t *testing.T) {
 		t.Logf("%q == %q", r, q.Removed)
 	}
 }
+
+func TestKeyAt(t *testing.T) {
+	re := regexp.MustCompile("^((ABC)|(XY))-[0-9]+")
+	b := []byte("Backlog for Ünits: 2 issues\n\nABC-1\tBug\tOpen\n\tfirst\nXY-22\tTask\tOpen\n\tsecond\n\tline\n")
+	for _, tc := range []struct {
+		Q0   int
+		Want string
+	}{
+		{Q0: 0, Want: ""},
+		{Q0: 29, Want: "ABC-1"},
+		{Q0: 40, Want: "ABC-1"},
+		{Q0: 52, Want: "XY-22"},
+		{Q0: len([]rune(string(b))) - 2, Want: "XY-22"},
+	} {
+		if got := keyAt(b, tc.Q0, re); got != tc.Want {
+			t.Errorf("%d: got %q, want %q", tc.Q0, got, tc.Want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Exported lists without any fields chosen get the same columns as the
+// "issues" template.
+var exportDefault = []string{"issuetype", "status", "summary"}
+
+// export writes every result of a list window's query to a file. Args should
+// be the format and then the path:
+//
+//	Export csv|json|md PATH
+func (u *UI) export(w *win, args []string) {
+	if len(args) != 2 {
+		u.err("usage: Export csv|json|md PATH")
+		return
+	}
+	format, fn := args[0], os.ExpandEnv(args[1])
+	names := w.fields
+	if len(names) == 0 {
+		names = exportDefault
+	}
+	ids := u.fieldIDs(names)
+
+	var rows [][]string
+	for total := 1; len(rows) < total; {
+		is, n, err := u.page(w.jql, len(rows), names)
+		if err != nil {
+			u.err(err.Error())
+			return
+		}
+		if len(is) == 0 {
+			break
+		}
+		total = n
+		for i := range is {
+			r := []string{is[i].Key}
+			for _, id := range ids {
+				r = append(r, fieldString(&is[i], id))
+			}
+			rows = append(rows, r)
+		}
+	}
+
+	if err := exportFile(fn, format, append([]string{"Key"}, names...), rows); err != nil {
+		u.err(fmt.Sprintf("Export: %v", err))
+		return
+	}
+	u.err(fmt.Sprintf("Export: wrote %d issues to %s", len(rows), fn))
+}
+
+// exportFile writes the rows to the file fn, in the named format.
+func exportFile(fn, format string, names []string, rows [][]string) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	if err := writeExport(f, format, names, rows); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeExport writes the rows, under the column names, in the named format.
+func writeExport(out io.Writer, format string, names []string, rows [][]string) error {
+	switch strings.ToLower(format) {
+	case "csv":
+		cw := csv.NewWriter(out)
+		cw.Write(names)
+		cw.WriteAll(rows)
+		return cw.Error()
+	case "json", "jsonl":
+		// The objects are put together by hand, as encoding a map would put
+		// the keys in sorted order, not the order of the columns.
+		for _, r := range rows {
+			var b bytes.Buffer
+			seen := make(map[string]bool, len(r))
+			b.WriteByte('{')
+			for i, v := range r {
+				if seen[names[i]] {
+					continue
+				}
+				seen[names[i]] = true
+				k, err := json.Marshal(names[i])
+				if err != nil {
+					return err
+				}
+				val, err := json.Marshal(v)
+				if err != nil {
+					return err
+				}
+				if i != 0 {
+					b.WriteByte(',')
+				}
+				b.Write(k)
+				b.WriteByte(':')
+				b.Write(val)
+			}
+			b.WriteString("}\n")
+			if _, err := out.Write(b.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "md", "markdown":
+		esc := strings.NewReplacer("|", `\|`, "\n", " ")
+		row := func(r []string) error {
+			var b strings.Builder
+			b.WriteByte('|')
+			for _, v := range r {
+				b.WriteString(" " + esc.Replace(v) + " |")
+			}
+			b.WriteByte('\n')
+			_, err := io.WriteString(out, b.String())
+			return err
+		}
+		if err := row(names); err != nil {
+			return err
+		}
+		sep := make([]string, len(names))
+		for i := range sep {
+			sep[i] = "---"
+		}
+		if err := row(sep); err != nil {
+			return err
+		}
+		for _, r := range rows {
+			if err := row(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
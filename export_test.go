@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteExport(t *testing.T) {
+	names := []string{"Key", "summary"}
+	rows := [][]string{
+		{"ABC-1", "a | pipe"},
+		{"ABC-2", `"quoted", with comma`},
+	}
+	for _, tc := range []struct {
+		Format string
+		Want   string
+	}{
+		{
+			Format: "csv",
+			Want:   "Key,summary\nABC-1,a | pipe\nABC-2,\"\"\"quoted\"\", with comma\"\n",
+		},
+		{
+			Format: "json",
+			Want:   "{\"Key\":\"ABC-1\",\"summary\":\"a | pipe\"}\n{\"Key\":\"ABC-2\",\"summary\":\"\\\"quoted\\\", with comma\"}\n",
+		},
+		{
+			Format: "md",
+			Want:   "| Key | summary |\n| --- | --- |\n| ABC-1 | a \\| pipe |\n| ABC-2 | \"quoted\", with comma |\n",
+		},
+	} {
+		var b strings.Builder
+		if err := writeExport(&b, tc.Format, names, rows); err != nil {
+			t.Errorf("%s: %v", tc.Format, err)
+			continue
+		}
+		if got := b.String(); got != tc.Want {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", tc.Format, got, tc.Want)
+		}
+	}
+
+	// JSON keys come in the order of the columns.
+	var b strings.Builder
+	if err := writeExport(&b, "json", []string{"Key", "summary", "assignee"}, [][]string{{"ABC-1", "s", "alice"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), `{"Key":"ABC-1","summary":"s","assignee":"alice"}`+"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := writeExport(&strings.Builder{}, "xls", names, rows); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+// Failures to write the file come back to be reported, not just logged.
+func TestExportFile(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"Key"}
+	rows := [][]string{{"ABC-1"}}
+	fn := filepath.Join(dir, "out.csv")
+	if err := exportFile(fn, "csv", names, rows); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(fn); err != nil || string(b) != "Key\nABC-1\n" {
+		t.Errorf("got %q, %v", b, err)
+	}
+	for _, tc := range []struct{ Name, File, Format string }{
+		{"missing directory", filepath.Join(dir, "nope", "out.csv"), "csv"},
+		{"directory", dir, "csv"},
+		{"unknown format", filepath.Join(dir, "out.xls"), "xls"},
+	} {
+		if err := exportFile(tc.File, tc.Format, names, rows); err == nil {
+			t.Errorf("%s: expected error", tc.Name)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+// MaxColumn is the widest a column other than the last is allowed to be.
+const maxColumn = 40
+
+const columnTimeFmt = "2006-01-02 15:04"
+
+// splitFields splits a comma-separated list of field names.
+func splitFields(s string) []string {
+	var r []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			r = append(r, f)
+		}
+	}
+	return r
+}
+
+// fieldIDs resolves the named fields, leaving names it doesn't know alone.
+func (u *UI) fieldIDs(names []string) []string {
+	r := make([]string, len(names))
+	for i, n := range names {
+		if r[i] = u.fieldID(n); r[i] == "" {
+			r[i] = n
+		}
+	}
+	return r
+}
+
+func userString(u *jira.User) string {
+	switch {
+	case u == nil:
+		return ""
+	case u.DisplayName != "":
+		return u.DisplayName
+	}
+	return u.Name
+}
+
+func timeString(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(columnTimeFmt)
+}
+
+// fieldString returns the field with ID id as a single line of text.
+func fieldString(i *jira.Issue, id string) string {
+	if id == "key" {
+		return i.Key
+	}
+	f := i.Fields
+	if f == nil {
+		return ""
+	}
+	var s []string
+	switch id {
+	case "summary":
+		return f.Summary
+	case "description":
+		return f.Description
+	case "environment":
+		return f.Environment
+	case "issuetype":
+		return f.Type.Name
+	case "project":
+		return f.Project.Key
+	case "status":
+		if f.Status != nil {
+			return f.Status.Name
+		}
+		return ""
+	case "priority":
+		if f.Priority != nil {
+			return f.Priority.Name
+		}
+		return ""
+	case "resolution":
+		if f.Resolution != nil {
+			return f.Resolution.Name
+		}
+		return ""
+	case "assignee":
+		return userString(f.Assignee)
+	case "reporter":
+		return userString(f.Reporter)
+	case "creator":
+		return userString(f.Creator)
+	case "created":
+		return timeString(time.Time(f.Created))
+	case "updated":
+		return timeString(time.Time(f.Updated))
+	case "resolutiondate":
+		return timeString(time.Time(f.Resolutiondate))
+	case "duedate":
+		if t := time.Time(f.Duedate); !t.IsZero() {
+			return t.Format("2006-01-02")
+		}
+		return ""
+	case "parent":
+		if f.Parent != nil {
+			return f.Parent.Key
+		}
+		return ""
+	case "labels":
+		s = f.Labels
+	case "components":
+		for _, c := range f.Components {
+			s = append(s, c.Name)
+		}
+	case "fixVersions":
+		for _, v := range f.FixVersions {
+			s = append(s, v.Name)
+		}
+	case "versions":
+		for _, v := range f.AffectsVersions {
+			s = append(s, v.Name)
+		}
+	default:
+		return valueString(f.Unknowns[id])
+	}
+	return strings.Join(s, ", ")
+}
+
+// valueString does its best to turn a field the jira package doesn't know
+// about into a line of text.
+func valueString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return formatPoints(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = valueString(v[i])
+		}
+		return strings.Join(s, ", ")
+	case map[string]interface{}:
+		for _, k := range []string{"displayName", "name", "value", "key"} {
+			if s, ok := v[k].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// columns lays out the named fields of the issues as aligned columns, under
+// a heading. The issue key is always the first column. Every line returned
+// ends in a newline.
+func columns(is []jira.Issue, names, ids []string) []string {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	row := func(cells []string) {
+		for i, c := range cells {
+			c = jqlSan.Replace(c)
+			if i != len(cells)-1 && utf8.RuneCountInString(c) > maxColumn {
+				c = string([]rune(c)[:maxColumn-1]) + "…"
+			}
+			if i != 0 {
+				tw.Write([]byte{'\t'})
+			}
+			tw.Write([]byte(c))
+		}
+		tw.Write([]byte{'\n'})
+	}
+	row(append([]string{"Key"}, names...))
+	for i := range is {
+		cells := []string{is[i].Key}
+		for _, id := range ids {
+			cells = append(cells, fieldString(&is[i], id))
+		}
+		row(cells)
+	}
+	tw.Flush()
+	// Empty cells at the end of a row leave padding behind.
+	lines := strings.SplitAfter(buf.String(), "\n")
+	lines = lines[:len(lines)-1]
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l[:len(l)-1], " ") + "\n"
+	}
+	return lines
+}
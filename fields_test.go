@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+func TestColumns(t *testing.T) {
+	long := strings.Repeat("x", maxColumn+10)
+	is := []jira.Issue{
+		{Key: "ABC-1", Fields: &jira.IssueFields{
+			Summary:  "Short",
+			Status:   &jira.Status{Name: "Open"},
+			Assignee: &jira.User{Name: "alice", DisplayName: "Alice A."},
+			Unknowns: map[string]interface{}{"customfield_1": 3.5},
+		}},
+		{Key: "ABC-10", Fields: &jira.IssueFields{
+			Summary:  long,
+			Status:   &jira.Status{Name: "In Progress"},
+			Unknowns: map[string]interface{}{"customfield_1": nil},
+		}},
+	}
+	names := []string{"summary", "status", "assignee", "Points"}
+	ids := []string{"summary", "status", "assignee", "customfield_1"}
+	trunc := strings.Repeat("x", maxColumn-1) + "…"
+	want := []string{
+		"Key     summary" + strings.Repeat(" ", maxColumn-7) + "  status       assignee  Points\n",
+		"ABC-1   Short" + strings.Repeat(" ", maxColumn-5) + "  Open         Alice A.  3.5\n",
+		"ABC-10  " + trunc + "  In Progress\n",
+	}
+	got := columns(is, names, ids)
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(got), len(want), strings.Join(got, ""))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d:\ngot  %q\nwant %q", i, got[i], want[i])
+		}
+	}
+
+	// The last column isn't cut short.
+	got = columns(is[1:], []string{"summary"}, []string{"summary"})
+	if want := "ABC-10  " + long + "\n"; got[1] != want {
+		t.Errorf("got %q, want %q", got[1], want)
+	}
+}
+
+func TestValueString(t *testing.T) {
+	for _, tc := range []struct {
+		Name string
+		In   interface{}
+		Want string
+	}{
+		{"nil", nil, ""},
+		{"string", "text", "text"},
+		{"number", 5.0, "5"},
+		{"fraction", 0.5, "0.5"},
+		{"bool", true, "true"},
+		{"user", map[string]interface{}{"name": "alice", "displayName": "Alice A.", "accountId": "123"}, "Alice A."},
+		{"option", map[string]interface{}{"self": "https://x", "value": "High", "id": "10000"}, "High"},
+		{"version", map[string]interface{}{"name": "1.0", "id": "2"}, "1.0"},
+		{"unknown map", map[string]interface{}{"id": "2"}, ""},
+		{"list", []interface{}{
+			map[string]interface{}{"value": "red"},
+			map[string]interface{}{"value": "green"},
+		}, "red, green"},
+		{"mixed list", []interface{}{"a", 2.0}, "a, 2"},
+	} {
+		if got := valueString(tc.In); got != tc.Want {
+			t.Errorf("%s: got %q, want %q", tc.Name, got, tc.Want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"9fans.net/go/acme"
+)
+
+// FilterPut is the subset of a filter that can be created or updated.
+type filterPut struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	JQL         string `json:"jql"`
+	Favourite   bool   `json:"favourite,omitempty"`
+}
+
+// parseFilters reads back the contents of the filters window, as written by
+// the "filters" template. The names are needed to make sense of filter names
+// with colons in them.
+func parseFilters(b []byte, names []string) []filterPut {
+	var r []filterPut
+	var jql []string
+	flush := func() {
+		if len(r) != 0 {
+			r[len(r)-1].JQL = strings.Join(jql, " ")
+		}
+		jql = jql[:0]
+	}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		l := s.Text()
+		switch {
+		case strings.TrimSpace(l) == "":
+		case strings.HasPrefix(l, "\t") || strings.HasPrefix(l, " "):
+			jql = append(jql, strings.TrimSpace(l))
+		default:
+			flush()
+			var f filterPut
+			if n := len(r); n < len(names) && strings.HasPrefix(l, names[n]+":") {
+				f.Name, f.Description = names[n], l[len(names[n])+1:]
+			} else {
+				f.Name, f.Description, _ = strings.Cut(l, ":")
+			}
+			f.Name = strings.TrimSpace(f.Name)
+			f.Description = strings.TrimSpace(f.Description)
+			r = append(r, f)
+		}
+	}
+	flush()
+	return r
+}
+
+// sameText reports whether the texts differ only in spacing, like after
+// being wrapped or put on one line.
+func sameText(a, b string) bool {
+	return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+}
+
+// putFilters updates any filters edited in the filters window.
+func (u *UI) putFilters(w *win) {
+	b, err := w.ReadAll("body")
+	if err != nil {
+		u.err(err.Error())
+		return
+	}
+	names := make([]string, len(w.filters))
+	for i, f := range w.filters {
+		names[i] = f.Name
+	}
+	fs := parseFilters(b, names)
+	if len(fs) != len(w.filters) {
+		u.err(fmt.Sprintf("found %d filters, expected %d: use SaveFilter in a search window to add one", len(fs), len(w.filters)))
+		return
+	}
+	for i, f := range w.filters {
+		up := fs[i]
+		if up.Name == f.Name && sameText(up.Description, f.Description) && sameText(up.JQL, f.Jql) {
+			continue
+		}
+		debug("updating filter %s: %+v", f.ID, up)
+		req, err := u.j.NewRequest("PUT", "/rest/api/2/filter/"+f.ID, &up)
+		if err != nil {
+			u.err(err.Error())
+			return
+		}
+		if _, err := u.j.Do(req, nil); err != nil {
+			u.err(fmt.Sprintf("error updating filter %q: %v", f.Name, err))
+		}
+	}
+}
+
+// saveFilter saves the query in a search window as a new favourite filter.
+func (u *UI) saveFilter(w *win, name string) {
+	if name == "" {
+		u.err("usage: SaveFilter NAME")
+		return
+	}
+	w.Addr("1")
+	b, err := w.ReadAll("xdata")
+	if err != nil {
+		u.err(err.Error())
+		return
+	}
+	_, q := parseSearch(string(b))
+	if q == "" {
+		u.err("SaveFilter: empty query")
+		return
+	}
+	f := filterPut{
+		Name:      name,
+		JQL:       q,
+		Favourite: true,
+	}
+	req, err := u.j.NewRequest("POST", "/rest/api/2/filter", &f)
+	if err != nil {
+		u.err(err.Error())
+		return
+	}
+	if _, err := u.j.Do(req, nil); err != nil {
+		u.err(fmt.Sprintf("error saving filter %q: %v", name, err))
+		return
+	}
+	if fw := u.show("filters"); fw != nil {
+		fw.Reload()
+	} else {
+		u.look("filters")
+	}
+}
+
+// lookFilter opens the results of a filter when its name is clicked in the
+// filters window.
+func (u *UI) lookFilter(w *win, e *acme.Event) bool {
+	if e.C2 != 'L' {
+		return false
+	}
+	b, err := w.ReadAll("body")
+	if err != nil {
+		u.err(err.Error())
+		return true
+	}
+	l := lineAt(b, e.Q0)
+	for _, f := range w.filters {
+		if strings.HasPrefix(l, f.Name+":") {
+			return u.look("filter/" + f.ID)
+		}
+	}
+	return false
+}
+
+// fetchFilter runs the query of the filter a window is named for.
+func (u *UI) fetchFilter(w *win) {
+	id, err := strconv.Atoi(strings.TrimPrefix(w.Title, "filter/"))
+	if err != nil {
+		u.err(fmt.Sprintf("bad filter ID: %v", err))
+		return
+	}
+	f, _, err := u.j.Filter.Get(id)
+	if err != nil {
+		u.err(err.Error())
+		return
+	}
+	w.jql = f.Jql
+	w.Ctl("nomark")
+	w.Addr("1")
+	w.Fprintf("data", "Filter: %s\n", f.Name)
+	w.Ctl("mark")
+	u.fetchList(w)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+// The filters window is read back line by line, so it has to survive
+// descriptions of more than one line and names with colons.
+func TestFiltersRoundTrip(t *testing.T) {
+	fs := []*jira.Filter{
+		{Name: "Team: open", Description: "Everything open.\n\nAsk alice first.\n", Jql: "project = ABC AND resolution is empty"},
+		{Name: "Mine", Jql: "assignee = currentUser()"},
+	}
+	var buf bytes.Buffer
+	if err := tmpls.ExecuteTemplate(&buf, "filters", fs); err != nil {
+		t.Fatal(err)
+	}
+	got := parseFilters(buf.Bytes(), []string{"Team: open", "Mine"})
+	if len(got) != len(fs) {
+		t.Fatalf("got %d filters, want %d:\n%s", len(got), len(fs), buf.String())
+	}
+	if got[0].Description != "Everything open. Ask alice first." {
+		t.Errorf("got description %q", got[0].Description)
+	}
+	for i, f := range fs {
+		up := got[i]
+		if up.Name != f.Name || !sameText(up.Description, f.Description) || !sameText(up.JQL, f.Jql) {
+			t.Errorf("filter %d: got %+v, want %+v", i, up, f)
+		}
+	}
+}
@@ -5,6 +5,7 @@ go 1.18
 require (
 	9fans.net/go v0.0.4
 	github.com/andygrunwald/go-jira v1.15.1
+	github.com/godbus/dbus/v5 v5.1.0
 )
 
 require (
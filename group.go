@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+// Group is a heading in a grouped list of issues. If the list is showing
+// columns, they're already laid out in Columns.
+type group struct {
+	Name    string
+	Issues  []jira.Issue
+	Columns string
+}
+
+const noGroup = "(none)"
+
+// groupField returns the ID of the field issues are grouped by.
+func (u *UI) groupField(by string) (string, error) {
+	switch by {
+	case "status", "assignee", "priority":
+		return by, nil
+	case "component", "components":
+		return "components", nil
+	case "epic":
+		if id := u.fieldID("Epic Link"); id != "" {
+			return id, nil
+		}
+		return "parent", nil
+	}
+	return "", fmt.Errorf("unable to group by %q: need one of status, assignee, component, priority, or epic", by)
+}
+
+// groupKeys returns the headings an issue belongs under.
+func groupKeys(i *jira.Issue, id string) []string {
+	var ks []string
+	switch {
+	case i.Fields == nil:
+	case id == "components":
+		for _, c := range i.Fields.Components {
+			ks = append(ks, c.Name)
+		}
+	case id == "assignee" && i.Fields.Assignee == nil:
+		ks = append(ks, "Unassigned")
+	case strings.HasPrefix(id, "customfield_"):
+		// An epic link: fall back to the parent, which is where newer
+		// versions put the epic.
+		k := fieldString(i, id)
+		if k == "" {
+			k = fieldString(i, "parent")
+		}
+		if k != "" {
+			ks = append(ks, k)
+		}
+	default:
+		if k := fieldString(i, id); k != "" {
+			ks = append(ks, k)
+		}
+	}
+	if len(ks) == 0 {
+		ks = append(ks, noGroup)
+	}
+	return ks
+}
+
+// groupIssues sorts the issues under headings by the field with ID id, in the
+// order the headings first appear.
+func groupIssues(is []jira.Issue, id string) []group {
+	var gs []group
+	idx := make(map[string]int)
+	for _, i := range is {
+		for _, k := range groupKeys(&i, id) {
+			n, ok := idx[k]
+			if !ok {
+				n = len(gs)
+				idx[k] = n
+				gs = append(gs, group{Name: k})
+			}
+			gs[n].Issues = append(gs[n].Issues, i)
+		}
+	}
+	return gs
+}
+
+// regroup handles the Group command of list windows. With no argument,
+// grouping is turned off.
+func (u *UI) regroup(w *win, by string) {
+	by = strings.ToLower(strings.TrimSpace(by))
+	if by != "" {
+		if _, err := u.groupField(by); err != nil {
+			u.err(err.Error())
+			return
+		}
+	}
+	w.group = by
+	// Lists with chosen fields need to fetch the field being grouped by.
+	// That's the query last run, not a new search to remember.
+	if by != "" && len(w.fields) != 0 {
+		u.fetchList(w)
+		return
+	}
+	if err := u.fill(w); err != nil {
+		u.err(err.Error())
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+func TestGroupIssues(t *testing.T) {
+	mk := func(key string, cs ...string) jira.Issue {
+		i := jira.Issue{Key: key, Fields: &jira.IssueFields{}}
+		for _, c := range cs {
+			i.Fields.Components = append(i.Fields.Components, &jira.Component{Name: c})
+		}
+		return i
+	}
+	// A-4 came back without fields at all.
+	is := []jira.Issue{mk("A-1", "ui"), mk("A-2"), mk("A-3", "api", "ui"), {Key: "A-4"}}
+	gs := groupIssues(is, "components")
+	want := map[string][]string{
+		"ui":    {"A-1", "A-3"},
+		noGroup: {"A-2", "A-4"},
+		"api":   {"A-3"},
+	}
+	if len(gs) != len(want) {
+		t.Fatalf("got %d groups, want %d", len(gs), len(want))
+	}
+	for i, name := range []string{"ui", noGroup, "api"} {
+		if gs[i].Name != name {
+			t.Errorf("group %d: got %q, want %q", i, gs[i].Name, name)
+		}
+		var keys []string
+		for _, i := range gs[i].Issues {
+			keys = append(keys, i.Key)
+		}
+		if strings.Join(keys, " ") != strings.Join(want[name], " ") {
+			t.Errorf("group %q: got %q, want %q", name, keys, want[name])
+		}
+	}
+
+	for _, id := range []string{"assignee", "status", "customfield_10014"} {
+		if got := groupKeys(&is[3], id); len(got) != 1 || got[0] != noGroup {
+			t.Errorf("%s: got %q, want [%s]", id, got, noGroup)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"9fans.net/go/acme"
+)
+
+// HistoryMax is the most queries the +History window lists.
+const historyMax = 100
+
+func historyFile() (string, error) {
+	d, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, "jira", "history"), nil
+}
+
+// record appends the query q to the search history. Every line of the history
+// file is a timestamp, the window prefix of the server searched, and the query,
+// separated by tabs.
+func (u *UI) record(q string) error {
+	fn, err := historyFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), u.prefix, jqlSan.Replace(q)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type histEntry struct {
+	At  time.Time
+	JQL string
+}
+
+// recent returns the most recent n distinct queries for the server with the
+// window prefix, newest first.
+func recent(b []byte, prefix string, n int) []histEntry {
+	var all []histEntry
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		f := strings.SplitN(s.Text(), "\t", 3)
+		if len(f) != 3 || f[1] != prefix {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, f[0])
+		if err != nil {
+			continue
+		}
+		all = append(all, histEntry{At: t, JQL: f[2]})
+	}
+	var r []histEntry
+	seen := make(map[string]bool)
+	for i := len(all) - 1; i >= 0 && len(r) < n; i-- {
+		if seen[all[i].JQL] {
+			continue
+		}
+		seen[all[i].JQL] = true
+		r = append(r, all[i])
+	}
+	return r
+}
+
+// history lists the recent queries in the +History window, where executing
+// one runs it again in the search window w.
+func (u *UI) history(w *win) {
+	fn, err := historyFile()
+	if err != nil {
+		u.err(err.Error())
+		return
+	}
+	b, err := os.ReadFile(fn)
+	if err != nil && !os.IsNotExist(err) {
+		u.err(err.Error())
+		return
+	}
+
+	var buf bytes.Buffer
+	for _, h := range recent(b, u.prefix, historyMax) {
+		fmt.Fprintf(&buf, "%s\t%s\n", h.At.Local().Format("2006-01-02 15:04"), h.JQL)
+	}
+	hw := u.show("+History")
+	if hw == nil {
+		hw = u.new("+History")
+		if hw == nil {
+			return
+		}
+	}
+	hw.Clear()
+	hw.Write("data", buf.Bytes())
+	hw.Ctl("clean")
+	hw.Addr("0")
+	hw.Ctl("dot=addr")
+	hw.Ctl("show")
+
+	title := w.Title
+	hw.exec = func(hw *win, _ string, e *acme.Event) bool {
+		// Only clicks in the body; commands in the tag are left alone.
+		if e.C2 != 'X' {
+			return false
+		}
+		b, err := hw.ReadAll("body")
+		if err != nil {
+			u.err(err.Error())
+			return true
+		}
+		_, q, ok := strings.Cut(lineAt(b, e.Q0), "\t")
+		if !ok || strings.TrimSpace(q) == "" {
+			return false
+		}
+		u.searchIn(title, nil, q)
+		return true
+	}
+}
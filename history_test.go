@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecent(t *testing.T) {
+	b := []byte(strings.Join([]string{
+		"2022-05-01T10:00:00Z\t/jira/corp\tproject = FOO",
+		"2022-05-01T11:00:00Z\t/jira/other\tproject = BAR",
+		"2022-05-01T12:00:00Z\t/jira/corp\tproject = BAZ",
+		"garbage",
+		"2022-05-01T13:00:00Z\t/jira/corp\tproject = FOO",
+		"",
+	}, "\n"))
+	got := recent(b, "/jira/corp", 10)
+	want := []string{"project = FOO", "project = BAZ"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %q", got, want)
+	}
+	for i := range want {
+		if got[i].JQL != want[i] {
+			t.Errorf("%d: got %q, want %q", i, got[i].JQL, want[i])
+		}
+	}
+	if got := recent(b, "/jira/corp", 1); len(got) != 1 {
+		t.Errorf("got %d entries, want 1", len(got))
+	}
+}
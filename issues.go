@@ -3,11 +3,10 @@ package main
 import (
 	"bytes"
 	"embed"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"net/url"
-	"path"
 	"sort"
 	"strings"
 	"text/template"
@@ -30,20 +29,20 @@ var (
 	tmplFuncs = map[string]any{
 		// See etc.go:/^func wrap
 		"wrap": wrap,
+		// Wiki renders wiki markup as plain text and wraps it; see wiki.go.
+		"wiki": wiki,
 		"join": strings.Join,
+		// Oneline puts text on a single line, for windows read back line
+		// by line.
+		"oneline": func(s string) string {
+			return strings.Join(strings.Fields(s), " ")
+		},
 		// Quote is actually "quote if contains space."
 		"quote": quote,
-		// Issuelink prints the URL that a user would use, given an API URL for an issue.
-		"issuelink": func(i *jira.Issue) (string, error) {
-			u, err := url.Parse(i.Self)
-			if err != nil {
-				return "", err
-			}
-			u, err = u.Parse(path.Join("/browse", i.Key))
-			if err != nil {
-				return "", err
-			}
-			return u.String(), nil
+		// Issuelink prints the URL that a user would use for an issue. That
+		// needs to know the site, so every UI has its own; see UI.issueLink.
+		"issuelink": func(*jira.Issue) (string, error) {
+			return "", errors.New("issuelink: no site to link to")
 		},
 		// Sort sorts the string.
 		"sort": func(s []string) []string {
@@ -53,6 +52,24 @@ var (
 		"time": func(t jira.Time) string {
 			return time.Time(t).Local().Format(time.RFC1123)
 		},
+		// Ago is how long ago a time (of any sort) was: "3h ago".
+		"ago": func(v interface{}) (string, error) {
+			t, err := toTime(v)
+			if err != nil {
+				return "", err
+			}
+			return relTime(t, time.Now()), nil
+		},
+		// Duration formats seconds of logged work: "1d 4h".
+		"duration": workDuration,
+		// Truncate and pad take the width first, for use in pipelines.
+		"truncate": truncate,
+		"pad":      pad,
+		// User is a user's display name.
+		"user": userString,
+		// Field returns an issue's field as text. In a UI's templates, the
+		// field can be given by name, not just ID.
+		"field": fieldString,
 		// Some times aren't parsed by the jira package, so this is a dedicated function for it.
 		"jiratime": func(in string) (string, error) {
 			t, err := time.Parse(jiraDateFmt, in)
@@ -75,14 +92,12 @@ type headers struct {
 	Labels     []string
 }
 
-func headersFromIssue(i *jira.Issue) *headers {
-	u, _ := url.Parse(i.Self)
-	br, _ := u.Parse("/browse/" + i.Key)
+func (u *UI) headersFromIssue(i *jira.Issue) *headers {
 	r := headers{
 		Summary: i.Fields.Summary,
 		Type:    i.Fields.Type.Name,
 		Project: i.Fields.Project.Name,
-		URL:     br.String(),
+		URL:     u.issueLink(i),
 		Labels:  i.Fields.Labels,
 	}
 	for _, c := range i.Fields.Components {
@@ -222,7 +237,7 @@ func (u *UI) issueTemplate() *win {
 	u.typesMu.Unlock()
 
 	var buf bytes.Buffer
-	if err := tmpls.ExecuteTemplate(&buf, "new", &data); err != nil {
+	if err := u.tmpls.ExecuteTemplate(&buf, "new", &data); err != nil {
 		u.err(err.Error())
 		return nil
 	}
@@ -332,7 +347,7 @@ func (u *UI) fetchIssue(w *win) {
 		w.Del(true)
 		return
 	}
-	w.headers = headersFromIssue(i)
+	w.headers = u.headersFromIssue(i)
 
 	req, err := u.j.NewRequest("GET", fmt.Sprintf("/rest/api/2/issue/%s/comment", i.ID), nil)
 	if err != nil {
@@ -342,7 +357,7 @@ func (u *UI) fetchIssue(w *win) {
 	}
 
 	var buf bytes.Buffer
-	if err := tmpls.ExecuteTemplate(&buf, "issue", i); err != nil {
+	if err := u.tmpls.ExecuteTemplate(&buf, "issue", i); err != nil {
 		u.err(err.Error())
 		w.Del(true)
 		return
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+// login prompts for a username and token, checks that the server accepts
+// them, and keeps them in the OS secret store where secretsOS will find them.
+func login(ctx context.Context, u *url.URL) error {
+	in := bufio.NewReader(os.Stdin)
+	fmt.Fprintf(os.Stderr, "Username for %s: ", u.Host)
+	user, err := in.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	user = strings.TrimSpace(user)
+	fmt.Fprintf(os.Stderr, "Token for %s: ", u.Host)
+	echo(false)
+	pass, err := in.ReadString('\n')
+	echo(true)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+	pass = strings.TrimSpace(pass)
+	if pass == "" {
+		return fmt.Errorf("no token given")
+	}
+	if strings.Contains(user, ":") {
+		return fmt.Errorf("username can't contain a colon")
+	}
+
+	c, err := authClient(ctx, *authType, u, user, pass)
+	if err != nil {
+		return err
+	}
+	j, err := jira.NewClient(c, u.String())
+	if err != nil {
+		return err
+	}
+	me, _, err := j.User.GetSelfWithContext(ctx)
+	if err != nil {
+		return fmt.Errorf("checking credentials: %w", err)
+	}
+	debug("logged in as %q", userString(me))
+
+	if err := storeOS(ctx, "Jira credentials for "+u.Host, user+":"+pass, "host", u.Host); err != nil {
+		return fmt.Errorf("storing credentials: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Stored credentials for %s (%s).\n", u.Host, userString(me))
+	return nil
+}
+
+// echo turns terminal echo on or off, if stdin is a terminal.
+func echo(on bool) {
+	arg := "-echo"
+	if on {
+		arg = "echo"
+	}
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	if err := cmd.Run(); err != nil {
+		debug("stty %s: %v", arg, err)
+	}
+}
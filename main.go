@@ -5,10 +5,13 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
+	"sort"
 	"strings"
 
 	jira "github.com/andygrunwald/go-jira"
@@ -16,14 +19,82 @@ import (
 )
 
 var (
-	authStr     = flag.String("a", "", "`username:personal_access_token` combination")
-	debugEnable = flag.Bool("D", false, "enable debug output")
-	noPlumber   = flag.Bool("p", false, "disable plumber integration and don't linger")
-	wrapWidth   = flag.Int("w", 80, "set wrap width")
+	authStr       = flag.String("a", "", "`username:token` combination")
+	authType      = flag.String("t", "auto", "auth `type`: basic (Cloud API tokens), pat (Data Center personal access tokens), or auto")
+	templateDir   = flag.String("T", "", "`directory` of templates overriding the built-in ones")
+	debugEnable   = flag.Bool("D", false, "enable debug output")
+	listFields    = flag.String("f", "", "comma-separated `fields` to show as columns in issue lists")
+	loginFlag     = flag.Bool("l", false, "prompt for credentials, check them, and store them in the OS secret store")
+	noPlumber     = flag.Bool("p", false, "disable plumber integration and don't linger")
+	oauthID       = flag.String("o", "", "use OAuth 2.0 with the app `id:secret` (Cloud only)")
+	oauthRedirect = flag.String("r", "", "OAuth callback `URL`, if not "+atlassianOAuth.RedirectURL)
+	wrapWidth     = flag.Int("w", 80, "set wrap width")
 
 	debug func(string, ...interface{}) = func(_ string, _ ...interface{}) {}
 )
 
+// QueryFlag adds to the home queries. Queries are given as NAME:JQL, or just
+// JQL to replace the "mine" query.
+type queryFlag map[string]string
+
+var homeName = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.*)$`)
+
+// ReservedNames are window names look handles itself, so home queries by
+// these names would never be shown.
+var reservedNames = map[string]bool{
+	"my-issues": true, "Mine": true, "new-issue": true,
+	"search": true, "Search": true, "filters": true, "Filters": true,
+	"filter": true, "backlog": true, "sprint": true,
+}
+
+// checkHomeName reports whether name can be used for a home query.
+func checkHomeName(name string) error {
+	if reservedNames[name] {
+		return fmt.Errorf("query name %q is taken by a built-in window", name)
+	}
+	return nil
+}
+
+func (q queryFlag) String() string {
+	return q["mine"]
+}
+
+func (q queryFlag) Set(s string) error {
+	name, jql := "mine", s
+	if m := homeName.FindStringSubmatch(s); m != nil {
+		name, jql = m[1], m[2]
+	}
+	if strings.TrimSpace(jql) == "" {
+		return fmt.Errorf("empty query for %q", name)
+	}
+	if err := checkHomeName(name); err != nil {
+		return err
+	}
+	q[name] = jql
+	return nil
+}
+
+// CmdQueries are the home queries given on the command line. They're kept
+// apart, as they override those in the configuration file for every server.
+var cmdQueries = queryFlag{}
+
+// ServerFlag collects the servers given in addition to the first.
+type serverFlag []string
+
+func (s *serverFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *serverFlag) Set(v string) error {
+	if _, err := url.Parse(v); err != nil {
+		return err
+	}
+	*s = append(*s, v)
+	return nil
+}
+
+var moreServers serverFlag
+
 const jiraDateFmt = "2006-01-02T15:04:05.000-0700"
 
 func usage() {
@@ -31,34 +102,105 @@ func usage() {
 	fmt.Fprintf(os.Stderr, "\t%s [options] server [win]\n\n", os.Args[0])
 	flag.PrintDefaults()
 	fmt.Fprintf(os.Stderr, "\n")
+	if fn, err := configFile(); err == nil {
+		fmt.Fprintf(os.Stderr, "Defaults and per-server settings are read from %s. Every\n", fn)
+		fmt.Fprintf(os.Stderr, "server there is used if none is given. Flags override the file.\n\n")
+	}
 	fmt.Fprintf(os.Stderr, "Credentials are looked for in a OS-specific secret store (linux only currently),\n")
-	fmt.Fprintf(os.Stderr, "then in ~/.jira-creds. The 'a' flag will override both. They're all expected to\n")
-	fmt.Fprintf(os.Stderr, "be in the same format.\n\n")
+	fmt.Fprintf(os.Stderr, "then in ~/.jira-creds, then in ~/.netrc. The 'a' flag overrides them all for\n")
+	fmt.Fprintf(os.Stderr, "the first server, which then doesn't look any up.\n")
+	fmt.Fprintf(os.Stderr, "For Atlassian Cloud, the username is the account's email address and the token\n")
+	fmt.Fprintf(os.Stderr, "is an API token. ~/.jira-creds has a \"HOST username:token\" line per server,\n")
+	fmt.Fprintf(os.Stderr, "or a lone \"username:token\" used for every server.\n\n")
+	fmt.Fprintf(os.Stderr, "The 'l' flag prompts for a username and token, and stores them in the OS\n")
+	fmt.Fprintf(os.Stderr, "secret store after checking them with the server.\n\n")
+	fmt.Fprintf(os.Stderr, "The 'c' flag changes where credentials are looked for. Sources are tried in\n")
+	fmt.Fprintf(os.Stderr, "order, and may be prefixed with a host to only apply to that server:\n\n")
+	fmt.Fprintf(os.Stderr, "\t- os\n")
+	fmt.Fprintf(os.Stderr, "\t- file\n")
+	fmt.Fprintf(os.Stderr, "\t- netrc\n")
+	fmt.Fprintf(os.Stderr, "\t- pass[:ENTRY] (default entry jira/HOST)\n")
+	fmt.Fprintf(os.Stderr, "\t- gopass[:ENTRY]\n")
+	fmt.Fprintf(os.Stderr, "\t- command:CMD (a git credential helper; must be last)\n\n")
+	fmt.Fprintf(os.Stderr, "With the 'o' flag, OAuth 2.0 is used instead. The first run prints (and plumbs)\n")
+	fmt.Fprintf(os.Stderr, "a URL to authorize Jira; the refresh token is then kept in the OS secret store.\n\n")
+	fmt.Fprintf(os.Stderr, "The 's' flag adds servers, each with windows under /jira/HOST. Issue keys sent\n")
+	fmt.Fprintf(os.Stderr, "by the plumber go to the server with that project; anything else goes to the\n")
+	fmt.Fprintf(os.Stderr, "first server. Without any servers on the command line, every server in the\n")
+	fmt.Fprintf(os.Stderr, "configuration file is used.\n\n")
+	fmt.Fprintf(os.Stderr, "Files in the 'T' flag's directory replace the built-in templates of the same\n")
+	fmt.Fprintf(os.Stderr, "name: issue, headers, description, comments, issues, groups, filters, backlog,\n")
+	fmt.Fprintf(os.Stderr, "sprint, and new. Put reads issues back using the lines of the built-in headers\n")
+	fmt.Fprintf(os.Stderr, "template, so an override of it should keep them.\n\n")
 	fmt.Fprintf(os.Stderr, "If a window name is supplied, it will be opened instead of \"my-issues\".\n")
 	fmt.Fprintf(os.Stderr, "Some special names include:\n\n")
 	fmt.Fprintf(os.Stderr, "\t- my-issues\n")
+	var names []string
+	for n := range homeQueries {
+		if _, ok := cmdQueries[n]; n != "mine" && !ok {
+			names = append(names, n)
+		}
+	}
+	for n := range cmdQueries {
+		if n != "mine" {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		fmt.Fprintf(os.Stderr, "\t- %s\n", n)
+	}
 	fmt.Fprintf(os.Stderr, "\t- search\n")
+	fmt.Fprintf(os.Stderr, "\t- search/NAME\n")
 	fmt.Fprintf(os.Stderr, "\t- filters\n")
+	fmt.Fprintf(os.Stderr, "\t- filter/ID\n")
+	fmt.Fprintf(os.Stderr, "\t- backlog/BOARD\n")
+	fmt.Fprintf(os.Stderr, "\t- sprint/ID\n")
 	fmt.Fprintf(os.Stderr, "\n")
 }
 
 func init() {
+	flag.Var(credSources, "c", "credential `sources` to try, as [HOST=]SOURCE,...; may be repeated for different hosts")
+	flag.Var(&moreServers, "s", "another `server` to talk to; may be repeated")
+	flag.Var(cmdQueries, "q", "add a home `query` as NAME:JQL, or replace the \"mine\" query with JQL")
 	flag.Usage = usage
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 }
 
 func main() {
-	var auth struct {
-		Err  error
-		User string
-		Pass string
-	}
-	var err error
 	ctx, done := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
 	defer done()
+	conf, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := conf.setFlags(flag.CommandLine); err != nil {
+		log.Fatal(err)
+	}
+	fileCreds = conf.creds()
+	for n, q := range conf.Queries {
+		homeQueries[n] = q
+	}
 	flag.Parse()
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
 
-	if flag.NArg() == 0 {
+	// Servers can be left out if the configuration lists some. Any given on
+	// the command line replace those.
+	args := flag.Args()
+	var srvs []string
+	if len(args) != 0 {
+		if u, err := url.Parse(args[0]); err == nil && isServer(u) {
+			srvs, args = append(srvs, args[0]), args[1:]
+		}
+	}
+	srvs = append(srvs, moreServers...)
+	if len(srvs) == 0 {
+		for _, sc := range conf.Servers {
+			srvs = append(srvs, sc.URL.String())
+		}
+	}
+	if len(srvs) == 0 {
 		log.Fatal("need to specify jira server")
 	}
 	if *debugEnable {
@@ -67,57 +209,128 @@ func main() {
 		}
 	}
 
-	jURL, err := url.Parse(flag.Arg(0))
-	if err != nil {
-		log.Fatal(err)
+	if *loginFlag {
+		jURL, err := url.Parse(srvs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if k := conf.authType(jURL); k != "" && !explicit["t"] {
+			*authType = k
+		}
+		if err := login(ctx, jURL); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
 	debug("hello")
-	// Ideally we'd use some OAuth2 stuff, but it requires server-side setup for some reason.
-	auth.User, auth.Pass, auth.Err = secretsOS(ctx, jURL.Host)
-	if auth.Err != nil {
-		debug("OS secret error: %v", auth.Err)
-		auth.User, auth.Pass, auth.Err = secretsFile()
+	var tmplErrs []error
+	if *templateDir != "" {
+		tmpls, tmplErrs = overrideTemplates(tmpls, *templateDir)
 	}
-	if *authStr != "" {
-		var ok bool
-		auth.User, auth.Pass, ok = strings.Cut(*authStr, ":")
-		if !ok {
-			log.Fatal(fmt.Errorf("unable to make sense of supplied username:password"))
+	var s servers
+	for i, srv := range srvs {
+		jURL, err := url.Parse(srv)
+		if err != nil {
+			log.Printf("%s: %v", srv, err)
+			continue
 		}
+		kind := *authType
+		if k := conf.authType(jURL); k != "" && !explicit["t"] {
+			kind = k
+		}
+		// The a flag is only for the first server: it's unlikely the
+		// same token works anywhere else.
+		var auth string
+		if i == 0 {
+			auth = *authStr
+		}
+		ui, err := connect(ctx, jURL, kind, auth)
+		if err != nil {
+			log.Printf("%s: %v", srv, err)
+			continue
+		}
+		if !ui.live() {
+			// Clean up any windows it managed to open complaining.
+			log.Printf("%s: unable to list projects", srv)
+			ui.leave()
+			continue
+		}
+		for n, q := range conf.queries(jURL, cmdQueries) {
+			ui.queries[n] = q
+		}
+		if sc := conf.server(jURL); sc != nil {
+			for n, id := range sc.Fields {
+				ui.names[n] = id
+			}
+		}
+		s = append(s, ui)
 	}
-
-	pat := jira.PATAuthTransport{
-		Token: auth.Pass,
-	}
-	c := pat.Client()
-	c.Jar, err = cookiejar.New(&cookiejar.Options{
-		PublicSuffixList: publicsuffix.List,
-	})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		return
-	}
-	j, err := jira.NewClient(c, jURL.String())
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
-		return
+	if len(s) == 0 {
+		log.Fatal("no usable servers")
 	}
-
-	ui, err := New(strings.TrimSuffix(jURL.Host, ".atlassian.net"), j)
-	if err != nil {
-		log.Fatal(err)
+	for _, err := range tmplErrs {
+		s[0].err(err.Error())
 	}
-	if flag.NArg() < 2 {
-		ui.look("my-issues")
+	if len(args) == 0 {
+		s[0].look("my-issues")
 	} else {
-		ui.look(strings.Join(flag.Args()[1:], " "))
+		s.look(strings.Join(args, " "))
 	}
-	go ui.plumber()
+	exited := s.wait()
+	go s.plumber(exited)
 
 	select {
-	case <-ui.exited:
+	case <-exited:
 	case <-ctx.Done():
 	}
 	debug("bye")
 }
+
+// connect sets up the client for the server, using the kind of auth, and
+// returns its UI. A username:token in userPass is used instead of looking
+// up credentials.
+func connect(ctx context.Context, jURL *url.URL, kind, userPass string) (*UI, error) {
+	var auth struct {
+		Err  error
+		User string
+		Pass string
+	}
+	var err error
+	var c *http.Client
+	api := jURL.String()
+	if *oauthID != "" {
+		c, api, err = oauthClient(ctx, jURL, *oauthID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if userPass != "" {
+			var ok bool
+			auth.User, auth.Pass, ok = strings.Cut(userPass, ":")
+			if !ok {
+				return nil, fmt.Errorf("unable to make sense of supplied username:password")
+			}
+		} else {
+			auth.User, auth.Pass, auth.Err = credentials(ctx, jURL.Host)
+			if auth.Err != nil {
+				debug("%v", auth.Err)
+			}
+		}
+		c, err = authClient(ctx, kind, jURL, auth.User, auth.Pass)
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.Jar, err = cookiejar.New(&cookiejar.Options{
+		PublicSuffixList: publicsuffix.List,
+	})
+	if err != nil {
+		return nil, err
+	}
+	j, err := jira.NewClient(c, api)
+	if err != nil {
+		return nil, err
+	}
+	return New(strings.TrimSuffix(jURL.Host, ".atlassian.net"), jURL, j)
+}
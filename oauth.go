@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"9fans.net/go/plumb"
+)
+
+// OauthConfig describes an OAuth 2.0 authorization code ("three-legged")
+// flow.
+type oauthConfig struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	// RedirectURL must be a loopback address, as a listener is started on
+	// it to receive the authorization code. A port of 0 picks any free port,
+	// if the authorization server allows that.
+	RedirectURL string
+	Scopes      []string
+	// Params are added to the authorization URL.
+	Params url.Values
+
+	// ResourcesURL lists the sites a token has access to, and APIURL is
+	// where a site's API lives once its ID is known.
+	ResourcesURL string
+	APIURL       string
+}
+
+// AtlassianOAuth is the configuration for Atlassian Cloud. The client ID and
+// secret come from an app registered in the Atlassian developer console, with
+// a callback URL matching the redirect.
+var atlassianOAuth = oauthConfig{
+	AuthURL:     "https://auth.atlassian.com/authorize",
+	TokenURL:    "https://auth.atlassian.com/oauth/token",
+	RedirectURL: "http://127.0.0.1:8123/callback",
+	// The classic scopes don't reach the Jira Software API (/rest/agile),
+	// used for boards and sprints, which needs granular ones.
+	Scopes: []string{
+		"read:jira-work", "write:jira-work", "read:jira-user", "offline_access",
+		"read:board-scope:jira-software", "write:board-scope:jira-software",
+		"read:sprint:jira-software", "write:sprint:jira-software",
+		"read:issue-details:jira", "read:jql:jira",
+	},
+	Params: url.Values{
+		"audience": {"api.atlassian.com"},
+		"prompt":   {"consent"},
+	},
+	ResourcesURL: "https://api.atlassian.com/oauth/token/accessible-resources",
+	APIURL:       "https://api.atlassian.com/ex/jira/",
+}
+
+type oauthToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// authorize runs the authorization code flow, handing the URL the user needs
+// to visit to open.
+func (c *oauthConfig) authorize(ctx context.Context, open func(string)) (*oauthToken, error) {
+	redir, err := url.Parse(c.RedirectURL)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", redir.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	redir.Host = l.Addr().String()
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	state := hex.EncodeToString(b)
+
+	type result struct {
+		code string
+		err  error
+	}
+	done := make(chan result, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(redir.Path, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var res result
+		switch {
+		case q.Get("state") != state:
+			http.Error(w, "bad state", http.StatusBadRequest)
+			return
+		case q.Get("error") != "":
+			res.err = fmt.Errorf("authorization failed: %s: %s", q.Get("error"), q.Get("error_description"))
+		default:
+			res.code = q.Get("code")
+		}
+		io.WriteString(w, "Jira is authorized; this window can be closed.\n")
+		select {
+		case done <- res:
+		default:
+		}
+	})
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	v := url.Values{}
+	for k, vs := range c.Params {
+		v[k] = vs
+	}
+	v.Set("client_id", c.ClientID)
+	v.Set("scope", strings.Join(c.Scopes, " "))
+	v.Set("redirect_uri", redir.String())
+	v.Set("state", state)
+	v.Set("response_type", "code")
+	open(c.AuthURL + "?" + v.Encode())
+
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+	return c.token(ctx, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {res.code},
+		"redirect_uri": {redir.String()},
+	})
+}
+
+// refresh exchanges the refresh token for a new token.
+func (c *oauthConfig) refresh(ctx context.Context, refresh string) (*oauthToken, error) {
+	tok, err := c.token(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refresh},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Refresh tokens aren't necessarily rotated.
+	if tok.RefreshToken == "" {
+		tok.RefreshToken = refresh
+	}
+	return tok, nil
+}
+
+func (c *oauthConfig) token(ctx context.Context, v url.Values) (*oauthToken, error) {
+	v.Set("client_id", c.ClientID)
+	v.Set("client_secret", c.ClientSecret)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("token request: %s: %s", res.Status, strings.TrimSpace(string(b)))
+	}
+	var tok oauthToken
+	if err := json.NewDecoder(res.Body).Decode(&tok); err != nil {
+		return nil, err
+	}
+	if tok.AccessToken == "" {
+		return nil, errors.New("token request: no access token returned")
+	}
+	return &tok, nil
+}
+
+// OauthTransport adds a bearer token to requests, refreshing it when the
+// server says it's no good.
+type oauthTransport struct {
+	conf *oauthConfig
+	base http.RoundTripper
+	// Save is called with every new token, to keep the refresh token.
+	save func(*oauthToken) error
+
+	mu  sync.Mutex
+	tok *oauthToken
+}
+
+func (t *oauthTransport) token() *oauthToken {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tok
+}
+
+// renew refreshes the token, unless it's already been replaced since old was
+// handed out.
+func (t *oauthTransport) renew(ctx context.Context, old *oauthToken) (*oauthToken, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tok != old {
+		return t.tok, nil
+	}
+	debug("refreshing OAuth token")
+	tok, err := t.conf.refresh(ctx, old.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	t.tok = tok
+	if t.save != nil {
+		if err := t.save(tok); err != nil {
+			debug("unable to save refresh token: %v", err)
+		}
+	}
+	return tok, nil
+}
+
+func (t *oauthTransport) send(req *http.Request, tok *oauthToken) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(r)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *oauthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tok := t.token()
+	if tok.AccessToken == "" {
+		var err error
+		if tok, err = t.renew(req.Context(), tok); err != nil {
+			return nil, err
+		}
+	}
+	res, err := t.send(req, tok)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+	// The request needs to be sent again, so make sure the body can be.
+	if req.Body != nil && req.GetBody == nil {
+		return res, nil
+	}
+	ntok, err := t.renew(req.Context(), tok)
+	if err != nil {
+		debug("unable to refresh token: %v", err)
+		return res, nil
+	}
+	res.Body.Close()
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if r.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return t.send(r, ntok)
+}
+
+// apiURL returns the URL the API for the site lives at, when using OAuth.
+func (t *oauthTransport) apiURL(ctx context.Context, site *url.URL) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.conf.ResourcesURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := (&http.Client{Transport: t}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("accessible resources: %s", res.Status)
+	}
+	var rs []struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&rs); err != nil {
+		return "", err
+	}
+	for _, r := range rs {
+		if u, err := url.Parse(r.URL); err == nil && u.Host == site.Host {
+			return t.conf.APIURL + r.ID + "/", nil
+		}
+	}
+	return "", fmt.Errorf("token has no access to %s", site.Host)
+}
+
+// openURL asks the user to visit u, and tries to get the plumber to open it.
+func openURL(u string) {
+	fmt.Fprintf(os.Stderr, "To authorize Jira, visit:\n\n\t%s\n\n", u)
+	if *noPlumber {
+		return
+	}
+	p, err := plumb.Open("send", 1) // WRONLY
+	if err != nil {
+		debug("unable to plumb authorization URL: %v", err)
+		return
+	}
+	defer p.Close()
+	m := plumb.Message{
+		Src:  "Jira",
+		Type: "text",
+		Data: []byte(u),
+	}
+	if err := m.Send(p); err != nil {
+		debug("unable to plumb authorization URL: %v", err)
+	}
+}
+
+// oauthClient returns an http.Client authorized with OAuth for the site, and
+// the URL of the site's API. Client is the OAuth app's "id:secret". Refresh
+// tokens are kept in the OS secret store, so the user only needs to go
+// through the authorization flow when there isn't one, or it's been revoked.
+func oauthClient(ctx context.Context, site *url.URL, client string) (*http.Client, string, error) {
+	conf := atlassianOAuth
+	var ok bool
+	conf.ClientID, conf.ClientSecret, ok = strings.Cut(client, ":")
+	if !ok {
+		return nil, "", errors.New("unable to make sense of OAuth client: need id:secret")
+	}
+	if *oauthRedirect != "" {
+		conf.RedirectURL = *oauthRedirect
+	}
+	host := site.Host
+	t := &oauthTransport{
+		conf: &conf,
+		save: func(tok *oauthToken) error {
+			return storeOS(ctx, "Jira OAuth refresh token for "+host, tok.RefreshToken, "oauth2_host", host)
+		},
+	}
+
+	if rt, err := lookupOS(ctx, "oauth2_host", host); err == nil && rt != "" {
+		t.tok = &oauthToken{RefreshToken: strings.TrimSpace(rt)}
+		if _, err := t.renew(ctx, t.tok); err != nil {
+			debug("stored refresh token: %v", err)
+			t.tok = nil
+		}
+	}
+	if t.tok == nil {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+		defer cancel()
+		tok, err := conf.authorize(ctx, openURL)
+		if err != nil {
+			return nil, "", err
+		}
+		t.tok = tok
+		if err := t.save(tok); err != nil {
+			debug("unable to save refresh token: %v", err)
+		}
+	}
+
+	api, err := t.apiURL(ctx, site)
+	if err != nil {
+		return nil, "", err
+	}
+	debug("using API at %s", api)
+	return &http.Client{Transport: t}, api, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+// FakeAuth is a stand-in authorization server. It hands out access tokens
+// "a1", "a2", ... and refresh tokens "r1", "r2", ..., and considers only the
+// newest access token valid. Like Atlassian's, its Jira Software API wants
+// granular scopes.
+type fakeAuth struct {
+	mu      sync.Mutex
+	n       int
+	code    string
+	scopes  map[string]bool
+	current string
+}
+
+// AgileScopes are the scopes the Jira Software API wants, by method.
+var agileScopes = map[string][]string{
+	http.MethodGet:  {"read:board-scope:jira-software", "read:sprint:jira-software", "read:issue-details:jira"},
+	http.MethodPut:  {"write:board-scope:jira-software"},
+	http.MethodPost: {"write:sprint:jira-software"},
+}
+
+func (f *fakeAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/authorize":
+		// Pretend the user clicked "accept."
+		q := r.URL.Query()
+		f.mu.Lock()
+		f.code = "code-" + q.Get("state")
+		f.scopes = make(map[string]bool)
+		for _, sc := range strings.Fields(q.Get("scope")) {
+			f.scopes[sc] = true
+		}
+		f.mu.Unlock()
+		redir, _ := url.Parse(q.Get("redirect_uri"))
+		v := url.Values{"code": {f.code}, "state": {q.Get("state")}}
+		redir.RawQuery = v.Encode()
+		http.Redirect(w, r, redir.String(), http.StatusFound)
+	case "/token":
+		r.ParseForm()
+		if r.Form.Get("client_id") != "id" || r.Form.Get("client_secret") != "secret" {
+			http.Error(w, "bad client", http.StatusUnauthorized)
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			if r.Form.Get("code") != f.code {
+				http.Error(w, "bad code", http.StatusBadRequest)
+				return
+			}
+		case "refresh_token":
+			if want := "r" + strings.TrimPrefix(f.current, "a"); r.Form.Get("refresh_token") != want {
+				http.Error(w, "bad refresh token", http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "bad grant", http.StatusBadRequest)
+			return
+		}
+		f.n++
+		f.current = "a" + string(rune('0'+f.n))
+		json.NewEncoder(w).Encode(&oauthToken{
+			AccessToken:  f.current,
+			RefreshToken: "r" + string(rune('0'+f.n)),
+			TokenType:    "Bearer",
+			ExpiresIn:    3600,
+		})
+	case "/api":
+		f.mu.Lock()
+		ok := r.Header.Get("Authorization") == "Bearer "+f.current
+		f.mu.Unlock()
+		if !ok {
+			http.Error(w, "expired", http.StatusUnauthorized)
+			return
+		}
+		io.Copy(w, r.Body)
+	case "/rest/agile/1.0/sprint/1":
+		f.mu.Lock()
+		ok := r.Header.Get("Authorization") == "Bearer "+f.current
+		for _, sc := range agileScopes[r.Method] {
+			ok = ok && f.scopes[sc]
+		}
+		f.mu.Unlock()
+		if !ok {
+			http.Error(w, "scope does not match", http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, `{"id":1,"name":"Sprint 1","state":"active"}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestOAuth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	fa := &fakeAuth{}
+	srv := httptest.NewServer(fa)
+	defer srv.Close()
+	conf := oauthConfig{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		AuthURL:      srv.URL + "/authorize",
+		TokenURL:     srv.URL + "/token",
+		RedirectURL:  "http://127.0.0.1:0/callback",
+		Scopes:       atlassianOAuth.Scopes,
+	}
+
+	// The "browser" follows the redirect back to the listener.
+	tok, err := conf.authorize(ctx, func(u string) {
+		go func() {
+			res, err := http.Get(u)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			res.Body.Close()
+		}()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "a1" || tok.RefreshToken != "r1" {
+		t.Fatalf("got token %+v", tok)
+	}
+
+	var saved []string
+	tr := &oauthTransport{
+		conf: &conf,
+		tok:  tok,
+		save: func(tok *oauthToken) error {
+			saved = append(saved, tok.RefreshToken)
+			return nil
+		},
+	}
+	c := &http.Client{Transport: tr}
+	post := func() string {
+		res, err := c.Post(srv.URL+"/api", "text/plain", strings.NewReader("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("got status %s", res.Status)
+		}
+		b, _ := io.ReadAll(res.Body)
+		return string(b)
+	}
+	if got := post(); got != "hello" {
+		t.Errorf("got body %q", got)
+	}
+	if len(saved) != 0 {
+		t.Errorf("refreshed needlessly: %q", saved)
+	}
+
+	// Hand the transport an access token the server doesn't know: the next
+	// request should refresh it and replay the body.
+	tr.mu.Lock()
+	tr.tok = &oauthToken{AccessToken: "stale", RefreshToken: "r1"}
+	tr.mu.Unlock()
+	if got := post(); got != "hello" {
+		t.Errorf("got body %q", got)
+	}
+	if len(saved) != 1 || saved[0] != "r2" {
+		t.Errorf("got saved refresh tokens %q, want [r2]", saved)
+	}
+	if got := tr.token().AccessToken; got != "a2" {
+		t.Errorf("got access token %q, want a2", got)
+	}
+
+	// Sprints are behind the Jira Software API.
+	j, err := jira.NewClient(c, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []string{http.MethodGet, http.MethodPut, http.MethodPost} {
+		req, err := j.NewRequest(m, "/rest/agile/1.0/sprint/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sp jira.Sprint
+		if _, err := j.Do(req, &sp); err != nil {
+			t.Errorf("%s sprint: %v", m, err)
+		} else if sp.Name != "Sprint 1" {
+			t.Errorf("%s sprint: got %+v", m, sp)
+		}
+	}
+}
+
+// Links are to the site, not the API, which is elsewhere with OAuth.
+func TestSiteURL(t *testing.T) {
+	tt := []struct {
+		site, want string
+	}{
+		{"https://corp.atlassian.net", "https://corp.atlassian.net/browse/ABC-1"},
+		{"https://jira.corp.com/jira/", "https://jira.corp.com/jira/browse/ABC-1"},
+	}
+	for _, tc := range tt {
+		site, err := url.Parse(tc.site)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u := &UI{site: site}
+		if got := u.issueLink(&jira.Issue{Key: "ABC-1"}); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
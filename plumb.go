@@ -3,11 +3,100 @@ package main
 import (
 	"bufio"
 	"log"
+	"strings"
 
 	"9fans.net/go/plumb"
 )
 
-func (ui *UI) plumber() {
+// Servers holds a UI for every server being talked to. The first is the
+// default.
+type servers []*UI
+
+// Changed is poked whenever a window closes or a UI exits, for wait to
+// look again.
+var changed = make(chan struct{}, 1)
+
+func poke() {
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
+
+// live reports whether the UI hasn't given up on its server.
+func (u *UI) live() bool {
+	select {
+	case <-u.exited:
+		return false
+	default:
+	}
+	return true
+}
+
+// route returns the UI that should handle title: the one whose window names
+// it starts with, the one owning the project of an issue key, or else the
+// default.
+func (s servers) route(title string) *UI {
+	var live []*UI
+	for _, u := range s {
+		if u.live() {
+			live = append(live, u)
+		}
+	}
+	if len(live) == 0 {
+		return nil
+	}
+	for _, u := range live {
+		if title == u.prefix || strings.HasPrefix(title, u.prefix+"/") {
+			return u
+		}
+	}
+	for _, u := range live {
+		if u.projRe != nil && u.projRe.MatchString(title) {
+			return u
+		}
+	}
+	return live[0]
+}
+
+func (s servers) look(title string) bool {
+	u := s.route(title)
+	if u == nil {
+		return false
+	}
+	return u.look(title)
+}
+
+// idle reports whether the UI has no windows open, or has exited.
+func (u *UI) idle() bool {
+	if !u.live() {
+		return true
+	}
+	u.Lock()
+	defer u.Unlock()
+	return len(u.win) == 0
+}
+
+// wait returns a channel that's closed once no UI has a window open.
+func (s servers) wait() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+	Wait:
+		for {
+			for _, u := range s {
+				if !u.idle() {
+					<-changed
+					continue Wait
+				}
+			}
+			return
+		}
+	}()
+	return done
+}
+
+func (s servers) plumber(done <-chan struct{}) {
 	if *noPlumber {
 		return
 	}
@@ -33,7 +122,7 @@ func (ui *UI) plumber() {
 	var m *plumb.Message
 	for {
 		select {
-		case <-ui.exited:
+		case <-done:
 			return
 		case m = <-msg:
 			if m == nil {
@@ -42,10 +131,14 @@ func (ui *UI) plumber() {
 		}
 		switch m.Type {
 		case "text":
-			ui.look(string(m.Data))
+			s.look(string(m.Data))
 		case "exit":
 			debug("told to leave via plumber")
-			ui.leave()
+			for _, u := range s {
+				if u.live() {
+					u.leave()
+				}
+			}
 			return
 		}
 	}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestRoute(t *testing.T) {
+	mk := func(prefix, re string) *UI {
+		return &UI{
+			prefix: prefix,
+			projRe: regexp.MustCompile(re),
+			exited: make(chan struct{}),
+		}
+	}
+	cloud := mk("/jira/corp", "^((ABC)|(CORP))-[0-9]+")
+	dc := mk("/jira/jira.corp.com", "^((OPS))-[0-9]+")
+	gone := mk("/jira/old.corp.com", "^((OLD))-[0-9]+")
+	close(gone.exited)
+	s := servers{cloud, dc, gone}
+
+	tt := []struct {
+		title string
+		want  *UI
+	}{
+		{"ABC-12", cloud},
+		{"OPS-3", dc},
+		{"/jira/jira.corp.com/search", dc},
+		{"/jira/jira.corp.com", dc},
+		{"/jira/corp/OPS-3", cloud},
+		{"search", cloud},
+		{"OLD-1", cloud},
+	}
+	for _, tc := range tt {
+		if got := s.route(tc.title); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.title, got.prefix, tc.want.prefix)
+		}
+	}
+
+	// Closing every window of a server doesn't stop keys going to it.
+	if got := s.route("OPS-3"); got != dc || !dc.idle() {
+		t.Errorf("got %q, want the idle server", got.prefix)
+	}
+
+	close(cloud.exited)
+	if got := s.route("ABC-12"); got != dc {
+		t.Errorf("got %q, want the first live server", got.prefix)
+	}
+}
+
+// A UI that gave up while connecting can still have its last window closed
+// afterwards.
+func TestExitTwice(t *testing.T) {
+	u := &UI{
+		win:    map[string]*win{"+Errors": nil},
+		exited: make(chan struct{}),
+	}
+	u.done()
+	u.exit("+Errors")
+	if u.live() {
+		t.Error("UI still live")
+	}
+}
+
+// Waiting is over once the last window of any server closes, not when every
+// server has exited.
+func TestWait(t *testing.T) {
+	busy := &UI{
+		win:    map[string]*win{"my-issues": nil},
+		exited: make(chan struct{}),
+	}
+	empty := &UI{
+		win:    map[string]*win{},
+		exited: make(chan struct{}),
+	}
+	done := servers{busy, empty}.wait()
+	select {
+	case <-done:
+		t.Fatal("done with a window open")
+	case <-time.After(10 * time.Millisecond):
+	}
+	busy.exit("my-issues")
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("not done after the last window closed")
+	}
+	if !busy.live() || !empty.live() {
+		t.Error("idle UI exited")
+	}
+}
@@ -2,8 +2,148 @@ package main
 
 import (
 	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	jira "github.com/andygrunwald/go-jira"
 )
 
+// PageSize is the number of issues asked for in every page of search results.
+const pageSize = 50
+
+// parseSearch splits a search line into the list of fields to show and the
+// query. A search line looks like:
+//
+//	Search[field,field] query
+//
+// Where the bracketed list of fields is optional.
+func parseSearch(line string) (fields []string, q string) {
+	fields, off := searchQuery(line)
+	return fields, strings.TrimSpace(line[off:])
+}
+
+// searchQuery returns the list of fields in a search line, and the offset of
+// the query in it.
+func searchQuery(line string) (fields []string, off int) {
+	rest := strings.TrimPrefix(line, "Search")
+	if strings.HasPrefix(rest, "[") {
+		if i := strings.IndexByte(rest, ']'); i > 0 {
+			fields = splitFields(rest[1:i])
+			rest = rest[i+1:]
+		}
+	}
+	rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+	return fields, len(line) - len(rest)
+}
+
+// splitSearchCmd pulls the window name out of a Search command, returning it
+// and a search line. Search commands look like a search line, but may also
+// name the window to search in:
+//
+//	Search:NAME[field,field] query
+func splitSearchCmd(cmd string) (name, line string) {
+	rest := strings.TrimPrefix(cmd, "Search")
+	var fields string
+	for {
+		switch {
+		case name == "" && strings.HasPrefix(rest, ":"):
+			i := strings.IndexFunc(rest, func(r rune) bool {
+				return r == '[' || unicode.IsSpace(r)
+			})
+			if i < 0 {
+				i = len(rest)
+			}
+			name, rest = rest[1:i], rest[i:]
+			if name == "" {
+				return "", "Search" + fields + rest
+			}
+		case fields == "" && strings.HasPrefix(rest, "["):
+			i := strings.IndexByte(rest, ']')
+			if i < 0 {
+				return name, "Search" + rest
+			}
+			fields, rest = rest[:i+1], rest[i+1:]
+		default:
+			return name, "Search" + fields + rest
+		}
+	}
+}
+
+// searchCmd runs a Search command in the search window it names, or the
+// default one. Any fields or query given (including the chorded argument
+// arg) replace those in the window's search line first.
+func (u *UI) searchCmd(cmd, arg string) {
+	name, line := splitSearchCmd(cmd)
+	title := "search"
+	if name != "" {
+		title = path.Join(title, name)
+	}
+	fs, q := parseSearch(line)
+	u.searchIn(title, fs, strings.TrimSpace(q+" "+arg))
+}
+
+// searchIn runs the search in the window title, replacing the fields or query
+// of its search line if given.
+func (u *UI) searchIn(title string, fs []string, q string) {
+	if !u.look(title) {
+		return
+	}
+	w := u.show(title)
+	if w == nil {
+		return
+	}
+	if fs != nil || q != "" {
+		w.Addr("1")
+		b, err := w.ReadAll("xdata")
+		if err != nil {
+			u.err(err.Error())
+			return
+		}
+		oldFs, oldQ := parseSearch(string(b))
+		if fs == nil {
+			fs = oldFs
+		}
+		if q == "" {
+			q = oldQ
+		}
+		w.Ctl("nomark")
+		w.Addr("1")
+		w.Fprintf("data", "Search")
+		if len(fs) != 0 {
+			w.Fprintf("data", "[%s]", strings.Join(fs, ","))
+		}
+		w.Fprintf("data", " %s\n", jqlSan.Replace(q))
+		w.Ctl("mark")
+	}
+	w.Reload()
+}
+
+// lookSearch is show-or-create for search windows.
+func (u *UI) lookSearch(title string) bool {
+	if w := u.show(title); w != nil {
+		return true
+	}
+	w := u.new(title)
+	if w == nil {
+		return false
+	}
+	w.Ctl("cleartag")
+	w.Fprintf("tag", " Get Clear More All Complete SaveFilter History ")
+	w.Fprintf("data", "Search %s\n", u.queries["mine"])
+	eol(w, 1)
+	w.Ctl("mark")
+	w.Ctl("clean")
+	w.Search = true
+	w.head = 1
+	w.reload = u.search
+	return true
+}
+
 func (u *UI) search(w *win) {
 	w.Addr("1")
 	b, err := w.ReadAll("xdata")
@@ -11,30 +151,235 @@ func (u *UI) search(w *win) {
 		u.err(err.Error())
 		return
 	}
-	q := string(bytes.TrimSpace(b[6:]))
+	fs, q := parseSearch(string(b))
 	if q == "" {
 		return
 	}
-	debug("searching: %q", q)
-
-	r, _, err := u.j.Issue.Search(q, nil)
+	if fs == nil {
+		fs = splitFields(*listFields)
+	}
+	errs, err := u.parseJQL(q)
 	if err != nil {
-		u.err(err.Error())
+		// Not every server has the parse endpoint; let the search report problems.
+		debug("unable to validate query: %v", err)
+	}
+	if len(errs) != 0 {
+		for _, e := range errs {
+			u.err(e)
+		}
+		if q0, q1, ok := errorSpan(q, errs[0]); ok {
+			_, off := searchQuery(string(b))
+			off = utf8.RuneCount(b[:off])
+			w.Addr("#%d,#%d", off+q0, off+q1)
+			w.Ctl("dot=addr")
+			w.Ctl("show")
+		}
 		return
 	}
+	w.jql, w.fields = q, fs
+	if err := u.record(q); err != nil {
+		debug("unable to record history: %v", err)
+	}
+	u.fetchList(w)
+}
+
+// parseJQL asks the server to parse the query q, returning any errors found.
+func (u *UI) parseJQL(q string) ([]string, error) {
+	body := struct {
+		Queries []string `json:"queries"`
+	}{
+		Queries: []string{q},
+	}
+	req, err := u.j.NewRequest("POST", "/rest/api/2/jql/parse?validation=strict", &body)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Queries []struct {
+			Errors []string `json:"errors"`
+		} `json:"queries"`
+	}
+	if _, err := u.j.Do(req, &res); err != nil {
+		return nil, err
+	}
+	var errs []string
+	for _, q := range res.Queries {
+		errs = append(errs, q.Errors...)
+	}
+	return errs, nil
+}
 
+var (
+	jqlErrPos   = regexp.MustCompile(`\(line (\d+), character (\d+)\)`)
+	jqlErrQuote = regexp.MustCompile(`'([^']+)'`)
+)
+
+// errorSpan reports the span of runes in the query q that the error message
+// msg complains about. Jira reports the position of syntax errors, but
+// other errors just quote the offending bit.
+func errorSpan(q, msg string) (q0, q1 int, ok bool) {
+	rs := []rune(q)
+	if m := jqlErrPos.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		char, _ := strconv.Atoi(m[2])
+		for i := 0; i < len(rs) && line > 1; i++ {
+			if rs[i] == '\n' {
+				line--
+				q0 = i + 1
+			}
+		}
+		q0 += char - 1
+		switch {
+		case q0 < 0:
+			return 0, 0, false
+		case q0 > len(rs):
+			q0 = len(rs)
+		}
+		q1 = q0
+		for q1 < len(rs) && !unicode.IsSpace(rs[q1]) {
+			q1++
+		}
+		return q0, q1, true
+	}
+	for _, m := range jqlErrQuote.FindAllStringSubmatch(msg, -1) {
+		if i := strings.Index(q, m[1]); i >= 0 {
+			q0 = utf8.RuneCountInString(q[:i])
+			return q0, q0 + utf8.RuneCountInString(m[1]), true
+		}
+	}
+	return 0, 0, false
+}
+
+// page runs the query q, returning the page of issues beginning at start and
+// the total number of issues matching. Only the named fields are fetched, if
+// any are given.
+func (u *UI) page(q string, start int, fields []string) ([]jira.Issue, int, error) {
+	debug("searching: %q from %d", q, start)
+	opts := jira.SearchOptions{
+		StartAt:    start,
+		MaxResults: pageSize,
+		Fields:     u.fieldIDs(fields),
+	}
+	is, res, err := u.j.Issue.Search(q, &opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return is, res.Total, nil
+}
+
+// listFields returns the fields a list window needs fetched: any chosen to be
+// shown, and the one it's grouped by.
+func (u *UI) listFields(w *win) []string {
+	if len(w.fields) == 0 || w.group == "" {
+		return w.fields
+	}
+	id, err := u.groupField(w.group)
+	if err != nil {
+		return w.fields
+	}
+	return append(w.fields[:len(w.fields):len(w.fields)], id)
+}
+
+// count is the line above a list of results.
+func (w *win) count() string {
+	return fmt.Sprintf("%d of %d issues\n", len(w.issues), w.total)
+}
+
+// fill replaces anything below the window's header with the results.
+func (u *UI) fill(w *win) error {
 	var buf bytes.Buffer
-	if err := tmpls.ExecuteTemplate(&buf, "issues", r); err != nil {
-		u.err(err.Error())
-		return
+	switch {
+	case w.group != "":
+		id, err := u.groupField(w.group)
+		if err != nil {
+			return err
+		}
+		gs := groupIssues(w.issues, id)
+		if len(w.fields) != 0 {
+			var all []jira.Issue
+			for _, g := range gs {
+				all = append(all, g.Issues...)
+			}
+			lines := columns(all, w.fields, u.fieldIDs(w.fields))
+			buf.WriteString(lines[0])
+			lines = lines[1:]
+			for i := range gs {
+				gs[i].Columns = strings.Join(lines[:len(gs[i].Issues)], "")
+				lines = lines[len(gs[i].Issues):]
+			}
+		}
+		if err := u.tmpls.ExecuteTemplate(&buf, "groups", gs); err != nil {
+			return err
+		}
+	case len(w.fields) == 0:
+		if err := u.tmpls.ExecuteTemplate(&buf, "issues", w.issues); err != nil {
+			return err
+		}
+	default:
+		buf.WriteString(strings.Join(columns(w.issues, w.fields, u.fieldIDs(w.fields)), ""))
 	}
 
 	w.Ctl("nomark")
-	w.Addr("2,")
-	w.Fprintf("data", "\n")
+	w.Addr("%d,", w.head+1)
+	w.Fprintf("data", "%s\n", w.count())
 	w.Write("data", buf.Bytes())
 	w.Ctl("mark")
 	w.Ctl("clean")
-	eol(w, 1)
+	return nil
+}
+
+// fetchList runs the window's query and shows the first page of results.
+func (u *UI) fetchList(w *win) {
+	if w.jql == "" {
+		return
+	}
+	is, total, err := u.page(w.jql, 0, u.listFields(w))
+	if err != nil {
+		u.err(err.Error())
+		return
+	}
+	w.issues, w.total = is, total
+	if err := u.fill(w); err != nil {
+		u.err(err.Error())
+		return
+	}
+	if w.Search {
+		eol(w, 1)
+	} else {
+		w.Addr("0")
+		w.Ctl("dot=addr")
+	}
+	w.Ctl("show")
+}
+
+// more adds the next page of results to the window, or every remaining page
+// if all is set.
+func (u *UI) more(w *win, all bool) {
+	n := len(w.issues)
+	for w.jql != "" && len(w.issues) < w.total {
+		is, total, err := u.page(w.jql, len(w.issues), u.listFields(w))
+		if err != nil {
+			u.err(err.Error())
+			break
+		}
+		if len(is) == 0 {
+			break
+		}
+		w.issues, w.total = append(w.issues, is...), total
+		if !all {
+			break
+		}
+	}
+	if len(w.issues) == n {
+		return
+	}
+	if err := u.fill(w); err != nil {
+		u.err(err.Error())
+		return
+	}
+	// Put the cursor on the first new issue.
+	w.Addr("#0")
+	w.Addr("/^%s/", regexp.QuoteMeta(w.issues[n].Key))
+	w.Ctl("dot=addr")
 	w.Ctl("show")
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSearch(t *testing.T) {
+	for _, tc := range []struct {
+		In     string
+		Fields []string
+		Query  string
+		Offset int
+	}{
+		{In: "Search project = FOO\n", Query: "project = FOO", Offset: 7},
+		{In: "Search", Query: "", Offset: 6},
+		{In: "Search[assignee, Story Points,] project = FOO", Fields: []string{"assignee", "Story Points"}, Query: "project = FOO", Offset: 32},
+		{In: "Search[] project = FOO", Query: "project = FOO", Offset: 9},
+		{In: "Search[status] status = Open", Fields: []string{"status"}, Query: "status = Open", Offset: 15},
+	} {
+		fs, q := parseSearch(tc.In)
+		if q != tc.Query {
+			t.Errorf("%q: got query %q, want %q", tc.In, q, tc.Query)
+		}
+		if _, off := searchQuery(tc.In); off != tc.Offset {
+			t.Errorf("%q: got offset %d, want %d", tc.In, off, tc.Offset)
+		}
+		if strings.Join(fs, ",") != strings.Join(tc.Fields, ",") {
+			t.Errorf("%q: got fields %q, want %q", tc.In, fs, tc.Fields)
+		}
+	}
+}
+
+func TestErrorSpan(t *testing.T) {
+	for _, tc := range []struct {
+		Query, Msg string
+		Want       string
+		OK         bool
+	}{
+		{
+			Query: "project = FOO AND stauts = Open",
+			Msg:   "Field 'stauts' does not exist or you do not have permission to view it.",
+			Want:  "stauts",
+			OK:    true,
+		},
+		{
+			Query: "project = FOO ANDD status = Open",
+			Msg:   "Error in the JQL Query: Expecting either 'OR' or 'AND' but got 'ANDD'. (line 1, character 15)",
+			Want:  "ANDD",
+			OK:    true,
+		},
+		{
+			Query: "summary ~ \"ünïcode\" ORDER BY",
+			Msg:   "Error in the JQL Query: Expecting a field name but got end of query. (line 1, character 30)",
+			Want:  "",
+			OK:    true,
+		},
+		{
+			Query: "project = FOO",
+			Msg:   "The value 'BAR' does not exist for the field 'project'.",
+			Want:  "project",
+			OK:    true,
+		},
+		{
+			Query: "project = FOO",
+			Msg:   "Something else went wrong.",
+		},
+	} {
+		q0, q1, ok := errorSpan(tc.Query, tc.Msg)
+		if ok != tc.OK {
+			t.Errorf("%q: got ok %v, want %v", tc.Msg, ok, tc.OK)
+			continue
+		}
+		if got := string([]rune(tc.Query)[q0:q1]); ok && got != tc.Want {
+			t.Errorf("%q: got %q, want %q", tc.Msg, got, tc.Want)
+		}
+	}
+}
+
+func TestSplitSearchCmd(t *testing.T) {
+	for _, tc := range []struct {
+		In         string
+		Name, Line string
+	}{
+		{In: "Search", Line: "Search"},
+		{In: "Search project = FOO", Line: "Search project = FOO"},
+		{In: "Search:triage project=FOO", Name: "triage", Line: "Search project=FOO"},
+		{In: "Search:triage", Name: "triage", Line: "Search"},
+		{In: "Search:triage[assignee] project=FOO", Name: "triage", Line: "Search[assignee] project=FOO"},
+		{In: "Search[assignee]:triage project=FOO", Name: "triage", Line: "Search[assignee] project=FOO"},
+		{In: "Search: project=FOO", Line: "Search project=FOO"},
+	} {
+		name, line := splitSearchCmd(tc.In)
+		if name != tc.Name || line != tc.Line {
+			t.Errorf("%q: got %q, %q; want %q, %q", tc.In, name, line, tc.Name, tc.Line)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// The freedesktop Secret Service API, as spoken by gnome-keyring, KWallet,
+// and KeePassXC. See https://specifications.freedesktop.org/secret-service/.
+const (
+	secretDest    = "org.freedesktop.secrets"
+	secretPath    = dbus.ObjectPath("/org/freedesktop/secrets")
+	secretIface   = "org.freedesktop.Secret."
+	secretDefault = dbus.ObjectPath("/org/freedesktop/secrets/aliases/default")
+	noPrompt      = dbus.ObjectPath("/")
+)
+
+var errSecretNotFound = errors.New("no matching secret")
+
+// SecretBus is the part of a D-Bus connection to the Secret Service that's
+// needed.
+type secretBus interface {
+	// Call calls the method on the object at path, storing the results in
+	// ret.
+	call(ctx context.Context, path dbus.ObjectPath, method string, args []interface{}, ret ...interface{}) error
+	// Prompt shows the prompt at path, waiting for the user to deal with it.
+	prompt(ctx context.Context, path dbus.ObjectPath) error
+}
+
+// DbusSecret is the Secret structure of the API.
+type dbusSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+type sessionBus struct {
+	conn *dbus.Conn
+}
+
+func (b *sessionBus) call(ctx context.Context, path dbus.ObjectPath, method string, args []interface{}, ret ...interface{}) error {
+	return b.conn.Object(secretDest, path).CallWithContext(ctx, secretIface+method, 0, args...).Store(ret...)
+}
+
+func (b *sessionBus) prompt(ctx context.Context, path dbus.ObjectPath) error {
+	opts := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(secretIface + "Prompt"),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := b.conn.AddMatchSignalContext(ctx, opts...); err != nil {
+		return err
+	}
+	defer b.conn.RemoveMatchSignalContext(ctx, opts...)
+	ch := make(chan *dbus.Signal, 1)
+	b.conn.Signal(ch)
+	defer b.conn.RemoveSignal(ch)
+	if err := b.call(ctx, path, "Prompt.Prompt", []interface{}{""}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case s := <-ch:
+			if s.Path != path || len(s.Body) == 0 {
+				continue
+			}
+			if dismissed, _ := s.Body[0].(bool); dismissed {
+				return errors.New("prompt dismissed")
+			}
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// SecretService is a session with the Secret Service.
+type secretService struct {
+	bus     secretBus
+	session dbus.ObjectPath
+}
+
+// openSecrets opens a session with the Secret Service. Secrets are sent
+// unencrypted ("plain"), as they're only going over the session bus.
+func openSecrets(ctx context.Context, bus secretBus) (*secretService, error) {
+	var out dbus.Variant
+	s := &secretService{bus: bus}
+	if err := bus.call(ctx, secretPath, "Service.OpenSession", []interface{}{"plain", dbus.MakeVariant("")}, &out, &s.session); err != nil {
+		return nil, fmt.Errorf("secret service: %w", err)
+	}
+	return s, nil
+}
+
+func (s *secretService) close(ctx context.Context) {
+	if err := s.bus.call(ctx, s.session, "Session.Close", nil); err != nil {
+		debug("secret service: closing session: %v", err)
+	}
+}
+
+// unlock unlocks the objects, prompting if need be.
+func (s *secretService) unlock(ctx context.Context, objs []dbus.ObjectPath) error {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	if err := s.bus.call(ctx, secretPath, "Service.Unlock", []interface{}{objs}, &unlocked, &prompt); err != nil {
+		return err
+	}
+	if prompt == noPrompt {
+		return nil
+	}
+	return s.bus.prompt(ctx, prompt)
+}
+
+// lookup returns the secret of the first item with the attributes.
+func (s *secretService) lookup(ctx context.Context, attrs map[string]string) (string, error) {
+	var unlocked, locked []dbus.ObjectPath
+	if err := s.bus.call(ctx, secretPath, "Service.SearchItems", []interface{}{attrs}, &unlocked, &locked); err != nil {
+		return "", err
+	}
+	var item dbus.ObjectPath
+	switch {
+	case len(unlocked) != 0:
+		item = unlocked[0]
+	case len(locked) != 0:
+		item = locked[0]
+		if err := s.unlock(ctx, locked[:1]); err != nil {
+			return "", fmt.Errorf("unlocking secret: %w", err)
+		}
+	default:
+		return "", errSecretNotFound
+	}
+	var sec dbusSecret
+	if err := s.bus.call(ctx, item, "Item.GetSecret", []interface{}{s.session}, &sec); err != nil {
+		return "", err
+	}
+	return string(sec.Value), nil
+}
+
+// store puts the secret in the default collection, replacing any item with
+// the same attributes.
+func (s *secretService) store(ctx context.Context, label, secret string, attrs map[string]string) error {
+	props := map[string]dbus.Variant{
+		secretIface + "Item.Label":      dbus.MakeVariant(label),
+		secretIface + "Item.Attributes": dbus.MakeVariant(attrs),
+	}
+	sec := dbusSecret{
+		Session:     s.session,
+		Parameters:  []byte{},
+		Value:       []byte(secret),
+		ContentType: "text/plain",
+	}
+	if err := s.unlock(ctx, []dbus.ObjectPath{secretDefault}); err != nil {
+		return fmt.Errorf("unlocking collection: %w", err)
+	}
+	var item, prompt dbus.ObjectPath
+	if err := s.bus.call(ctx, secretDefault, "Collection.CreateItem", []interface{}{props, sec, true}, &item, &prompt); err != nil {
+		return err
+	}
+	if prompt == noPrompt {
+		return nil
+	}
+	return s.bus.prompt(ctx, prompt)
+}
+
+// secretAttrs turns name-value pairs into the attributes for one of this
+// program's secrets, the same as secret-tool would be given.
+func secretAttrs(attrs []string) map[string]string {
+	m := map[string]string{"app_id": secretAppID}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		m[attrs[i]] = attrs[i+1]
+	}
+	return m
+}
+
+// withSecrets calls f with a session on the Secret Service.
+func withSecrets(ctx context.Context, f func(*secretService) error) error {
+	conn, err := dbus.ConnectSessionBus(dbus.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	s, err := openSecrets(ctx, &sessionBus{conn: conn})
+	if err != nil {
+		return err
+	}
+	defer s.close(ctx)
+	return f(s)
+}
+
+func lookupDbus(ctx context.Context, attrs ...string) (string, error) {
+	var r string
+	err := withSecrets(ctx, func(s *secretService) (err error) {
+		r, err = s.lookup(ctx, secretAttrs(attrs))
+		return err
+	})
+	return r, err
+}
+
+func storeDbus(ctx context.Context, label, secret string, attrs ...string) error {
+	return withSecrets(ctx, func(s *secretService) error {
+		return s.store(ctx, label, secret, secretAttrs(attrs))
+	})
+}
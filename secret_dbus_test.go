@@ -0,0 +1,289 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <listen>unix:dir=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// privateBus starts a bus of the test's own, and makes it the session bus.
+func privateBus(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("no dbus-daemon")
+	}
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(conf, []byte(fmt.Sprintf(busConfig, dir)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(daemon, "--config-file="+conf, "--nofork", "--print-address")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading bus address: %v", err)
+	}
+	addr = strings.TrimSpace(addr)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", addr)
+	return addr
+}
+
+// FakeSecrets stands in for the Secret Service on the bus. It holds one
+// collection, which starts out locked and needs a prompt to unlock.
+type fakeSecrets struct {
+	conn *dbus.Conn
+
+	mu      sync.Mutex
+	locked  bool
+	prompts int
+	items   map[dbus.ObjectPath]*fakeItem
+	n       int
+}
+
+type fakeItem struct {
+	label  string
+	attrs  map[string]string
+	secret []byte
+}
+
+const (
+	fakeSession = dbus.ObjectPath("/org/freedesktop/secrets/session/1")
+	fakePrompt  = dbus.ObjectPath("/org/freedesktop/secrets/prompt/1")
+)
+
+func serveSecrets(t *testing.T, addr string) *fakeSecrets {
+	t.Helper()
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	f := &fakeSecrets{
+		conn:   conn,
+		locked: true,
+		items:  make(map[dbus.ObjectPath]*fakeItem),
+	}
+	for _, e := range []struct {
+		path    dbus.ObjectPath
+		iface   string
+		methods map[string]interface{}
+	}{
+		{secretPath, "Service", map[string]interface{}{
+			"OpenSession": f.openSession,
+			"SearchItems": f.searchItems,
+			"Unlock":      f.unlock,
+		}},
+		{fakeSession, "Session", map[string]interface{}{
+			"Close": func() *dbus.Error { return nil },
+		}},
+		{fakePrompt, "Prompt", map[string]interface{}{
+			"Prompt": f.prompt,
+		}},
+		{secretDefault, "Collection", map[string]interface{}{
+			"CreateItem": f.createItem,
+		}},
+	} {
+		if err := conn.ExportMethodTable(e.methods, e.path, secretIface+e.iface); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := conn.RequestName(secretDest, dbus.NameFlagDoNotQueue)
+	if err != nil || r != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("unable to own %s: %v, %v", secretDest, r, err)
+	}
+	return f
+}
+
+func (f *fakeSecrets) openSession(algorithm string, input dbus.Variant) (dbus.Variant, dbus.ObjectPath, *dbus.Error) {
+	if s, ok := input.Value().(string); algorithm != "plain" || !ok || s != "" {
+		return dbus.Variant{}, "", dbus.MakeFailedError(fmt.Errorf("unsupported algorithm %q, %v", algorithm, input))
+	}
+	return dbus.MakeVariant(""), fakeSession, nil
+}
+
+func (f *fakeSecrets) promptCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.prompts
+}
+
+func (f *fakeSecrets) search(attrs map[string]string) []dbus.ObjectPath {
+	r := []dbus.ObjectPath{}
+Items:
+	for p, it := range f.items {
+		for k, v := range attrs {
+			if it.attrs[k] != v {
+				continue Items
+			}
+		}
+		r = append(r, p)
+	}
+	return r
+}
+
+func (f *fakeSecrets) searchItems(attrs map[string]string) ([]dbus.ObjectPath, []dbus.ObjectPath, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ps := f.search(attrs)
+	if f.locked {
+		return []dbus.ObjectPath{}, ps, nil
+	}
+	return ps, []dbus.ObjectPath{}, nil
+}
+
+func (f *fakeSecrets) unlock(objs []dbus.ObjectPath) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.locked {
+		return []dbus.ObjectPath{}, fakePrompt, nil
+	}
+	return objs, noPrompt, nil
+}
+
+func (f *fakeSecrets) prompt(window string) *dbus.Error {
+	f.mu.Lock()
+	f.prompts++
+	f.locked = false
+	f.mu.Unlock()
+	if err := f.conn.Emit(fakePrompt, secretIface+"Prompt.Completed", false, dbus.MakeVariant("")); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (f *fakeSecrets) createItem(props map[string]dbus.Variant, sec dbusSecret, replace bool) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.locked {
+		return "", "", dbus.MakeFailedError(errors.New("locked"))
+	}
+	if sec.Session != fakeSession || sec.ContentType != "text/plain" {
+		return "", "", dbus.MakeFailedError(fmt.Errorf("bad secret %+v", sec))
+	}
+	attrs, ok := props[secretIface+"Item.Attributes"].Value().(map[string]string)
+	if !ok {
+		return "", "", dbus.MakeFailedError(errors.New("bad attributes"))
+	}
+	label, _ := props[secretIface+"Item.Label"].Value().(string)
+	it := &fakeItem{label: label, attrs: attrs, secret: sec.Value}
+	if ps := f.search(attrs); len(ps) != 0 && replace {
+		f.items[ps[0]] = it
+		return ps[0], noPrompt, nil
+	}
+	f.n++
+	p := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/secrets/collection/login/%d", f.n))
+	f.items[p] = it
+	err := f.conn.ExportMethodTable(map[string]interface{}{
+		"GetSecret": func(session dbus.ObjectPath) (dbusSecret, *dbus.Error) {
+			return f.getSecret(p, session)
+		},
+	}, p, secretIface+"Item")
+	if err != nil {
+		return "", "", dbus.MakeFailedError(err)
+	}
+	return p, noPrompt, nil
+}
+
+func (f *fakeSecrets) getSecret(p, session dbus.ObjectPath) (dbusSecret, *dbus.Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case f.locked:
+		return dbusSecret{}, dbus.MakeFailedError(errors.New("locked"))
+	case session != fakeSession:
+		return dbusSecret{}, dbus.MakeFailedError(errors.New("bad session"))
+	}
+	return dbusSecret{
+		Session:     session,
+		Parameters:  []byte{},
+		Value:       f.items[p].secret,
+		ContentType: "text/plain",
+	}, nil
+}
+
+func TestSecretService(t *testing.T) {
+	ctx := context.Background()
+	f := serveSecrets(t, privateBus(t))
+
+	if _, err := lookupDbus(ctx, "host", "jira.example.com"); !errors.Is(err, errSecretNotFound) {
+		t.Errorf("got %v, want %v", err, errSecretNotFound)
+	}
+	if err := storeDbus(ctx, "Jira credentials", "alice:one", "host", "jira.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.promptCount(); n != 1 {
+		t.Errorf("got %d prompts, want 1", n)
+	}
+	if err := storeDbus(ctx, "Jira credentials", "alice:two", "host", "jira.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storeDbus(ctx, "Jira OAuth refresh token", "refresh", "oauth2_host", "jira.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	if len(f.items) != 2 {
+		t.Errorf("got %d items, want 2", len(f.items))
+	}
+	for _, it := range f.items {
+		if it.attrs["app_id"] != secretAppID {
+			t.Errorf("item %q missing app_id", it.label)
+		}
+	}
+	// Lock it up again: looking up should prompt.
+	f.locked = true
+	f.mu.Unlock()
+
+	got, err := lookupDbus(ctx, "host", "jira.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "alice:two" {
+		t.Errorf("got %q, want %q", got, "alice:two")
+	}
+	if n := f.promptCount(); n != 2 {
+		t.Errorf("got %d prompts, want 2", n)
+	}
+	got, err = lookupDbus(ctx, "oauth2_host", "jira.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "refresh" {
+		t.Errorf("got %q, want %q", got, "refresh")
+	}
+}
@@ -1,18 +1,71 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
-	"io/ioutil"
+	"fmt"
 	"os"
+	"strings"
 )
 
-func secretsFile() (string, string, error) {
+// secretsFile looks up the credentials for host in ~/.jira-creds. The file
+// has a line per server, giving the host and then the credentials:
+//
+//	corp.atlassian.net alice@corp.com:token
+//	jira.corp.com:8443 alice:token
+//
+// Blank lines and lines starting with "#" are ignored. A file holding just
+// "user:token" is used for every server.
+func secretsFile(host string) (string, string, error) {
 	fn := os.ExpandEnv("${HOME}/.jira-creds")
-	b, err := ioutil.ReadFile(fn)
+	b, err := os.ReadFile(fn)
 	if err != nil {
 		return "", "", err
 	}
+	user, pass, ok := parseCredsFile(b, host)
+	if !ok {
+		return "", "", fmt.Errorf("%s: no entry for %q", fn, host)
+	}
+	return user, pass, nil
+}
 
-	upw := bytes.SplitN(bytes.TrimSpace(b), []byte(":"), 2)
-	return string(upw[0]), string(upw[1]), nil
+func parseCredsFile(b []byte, host string) (string, string, bool) {
+	name := host
+	if h, _, ok := strings.Cut(host, ":"); ok {
+		name = h
+	}
+	var byName string
+	var lines []string
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		lines = append(lines, l)
+		h, upw, ok := strings.Cut(l, " ")
+		if !ok {
+			continue
+		}
+		upw = strings.TrimSpace(upw)
+		switch h {
+		case host:
+			u, p, _ := strings.Cut(upw, ":")
+			return u, p, true
+		case name:
+			if byName == "" {
+				byName = upw
+			}
+		}
+	}
+	if byName != "" {
+		u, p, _ := strings.Cut(byName, ":")
+		return u, p, true
+	}
+	// The old format: just the one set of credentials.
+	if len(lines) == 1 && !strings.Contains(lines[0], " ") {
+		u, p, _ := strings.Cut(lines[0], ":")
+		return u, p, true
+	}
+	return "", "", false
 }
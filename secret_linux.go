@@ -5,20 +5,59 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
 )
 
+const secretAppID = "io.github.hdonnay.Jira"
+
 func secretsOS(ctx context.Context, name string) (string, string, error) {
-	cmd := exec.CommandContext(ctx, "secret-tool", "lookup", "app_id", "io.github.hdonnay.Jira", "host", name)
-	out, err := cmd.Output()
+	out, err := lookupOS(ctx, "host", name)
 	if err != nil {
 		return "", "", fmt.Errorf("secret %q not found: %w", name, err)
 	}
-	u, p, ok := strings.Cut(string(out), ":")
+	u, p, ok := strings.Cut(strings.TrimSpace(out), ":")
 	if !ok {
 		return "", "", fmt.Errorf("secret %q not found: bad format", name)
 	}
 	return u, p, nil
 }
+
+// lookupOS returns the secret stored for this program with the attributes,
+// given as name-value pairs. The Secret Service is talked to directly, and
+// secret-tool is used if that doesn't work out.
+func lookupOS(ctx context.Context, attrs ...string) (string, error) {
+	s, err := lookupDbus(ctx, attrs...)
+	switch {
+	case err == nil:
+		return s, nil
+	case errors.Is(err, errSecretNotFound):
+		return "", err
+	}
+	debug("secret service: %v; trying secret-tool", err)
+	args := append([]string{"lookup", "app_id", secretAppID}, attrs...)
+	out, err := exec.CommandContext(ctx, "secret-tool", args...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// storeOS stores the secret for this program with the attributes, given as
+// name-value pairs, replacing any existing secret with the same attributes.
+func storeOS(ctx context.Context, label, secret string, attrs ...string) error {
+	err := storeDbus(ctx, label, secret, attrs...)
+	if err == nil {
+		return nil
+	}
+	debug("secret service: %v; trying secret-tool", err)
+	args := append([]string{"store", "--label", label, "app_id", secretAppID}, attrs...)
+	cmd := exec.CommandContext(ctx, "secret-tool", args...)
+	cmd.Stdin = strings.NewReader(secret)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
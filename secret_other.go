@@ -3,8 +3,21 @@
 
 package main
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
-func secretsOS(_ string) (string, string, error) {
-	return "", "", fmt.Errorf("OS secret retrival unsupported here")
+var errNoSecrets = fmt.Errorf("OS secret retrival unsupported here")
+
+func secretsOS(_ context.Context, _ string) (string, string, error) {
+	return "", "", errNoSecrets
+}
+
+func lookupOS(_ context.Context, _ ...string) (string, error) {
+	return "", errNoSecrets
+}
+
+func storeOS(_ context.Context, _, _ string, _ ...string) error {
+	return errNoSecrets
 }
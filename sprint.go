@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"9fans.net/go/acme"
+	jira "github.com/andygrunwald/go-jira"
+)
+
+// The Sprint type in the jira package is missing the goal.
+type sprint struct {
+	ID           int        `json:"id"`
+	Name         string     `json:"name"`
+	State        string     `json:"state"`
+	Goal         string     `json:"goal,omitempty"`
+	StartDate    *time.Time `json:"startDate,omitempty"`
+	EndDate      *time.Time `json:"endDate,omitempty"`
+	CompleteDate *time.Time `json:"completeDate,omitempty"`
+}
+
+// SprintView is what the "sprint" template is executed with.
+type sprintView struct {
+	*sprint
+	Start, End  string
+	Total, Done string
+	Issues      []sprintIssue
+	Burndown    []burnDay
+}
+
+type sprintIssue struct {
+	Key     string
+	Type    string
+	Status  string
+	Summary string
+	Points  string
+
+	points float64
+	// Whether the issue was done before the first change in done-ness.
+	was     bool
+	changes []doneChange
+}
+
+// DoneChange is a status transition, recording whether the statuses on
+// either side of it count as "done".
+type doneChange struct {
+	At   time.Time
+	Was  bool
+	Done bool
+}
+
+// DoneAt reports whether the issue was done at time t.
+func (i *sprintIssue) doneAt(t time.Time) bool {
+	done := i.was
+	for _, c := range i.changes {
+		if c.At.After(t) {
+			break
+		}
+		done = c.Done
+	}
+	return done
+}
+
+type burnDay struct {
+	Day       string
+	Remaining string
+	Bar       string
+}
+
+// Burndown reports the points remaining at the end of every day from start
+// through end, stopping at now.
+func burndown(start, end, now time.Time, is []sprintIssue) []burnDay {
+	if end.After(now) {
+		end = now
+	}
+	var total float64
+	for _, i := range is {
+		total += i.points
+	}
+	barMax := *wrapWidth - 24
+	if barMax < 10 {
+		barMax = 10
+	}
+	var r []burnDay
+	y, m, d := start.Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, start.Location()); !day.After(end); day = day.AddDate(0, 0, 1) {
+		eod := day.AddDate(0, 0, 1)
+		if eod.After(now) {
+			eod = now
+		}
+		var rem float64
+		for i := range is {
+			if !is[i].doneAt(eod) {
+				rem += is[i].points
+			}
+		}
+		bar := 0
+		if total != 0 {
+			bar = int(rem / total * float64(barMax))
+		}
+		r = append(r, burnDay{
+			Day:       day.Format("Mon Jan 02"),
+			Remaining: formatPoints(rem),
+			Bar:       strings.Repeat("#", bar),
+		})
+	}
+	return r
+}
+
+func formatPoints(p float64) string {
+	return strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// pointsField returns the ID of the field holding story points.
+func (u *UI) pointsField() string {
+	for _, n := range []string{"Story Points", "Story point estimate"} {
+		if id := u.fieldID(n); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+func (u *UI) getSprint(id string) (*sprint, error) {
+	req, err := u.j.NewRequest("GET", "/rest/agile/1.0/sprint/"+id, nil)
+	if err != nil {
+		return nil, err
+	}
+	var s sprint
+	if _, err := u.j.Do(req, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (u *UI) fetchSprint(w *win) {
+	id := strings.TrimPrefix(w.Title, "sprint/")
+	s, err := u.getSprint(id)
+	if err != nil {
+		u.err(err.Error())
+		return
+	}
+
+	// Need to know which statuses count as "done" to make sense of the changelogs.
+	req, err := u.j.NewRequest("GET", "/rest/api/2/status", nil)
+	if err != nil {
+		u.err(err.Error())
+		return
+	}
+	var sts []jira.Status
+	if _, err := u.j.Do(req, &sts); err != nil {
+		u.err(err.Error())
+		return
+	}
+	done := make(map[string]bool)
+	for _, st := range sts {
+		done[st.ID] = st.StatusCategory.Key == jira.StatusCategoryComplete
+	}
+
+	pf := u.pointsField()
+	fields := "issuetype,status,summary"
+	if pf != "" {
+		fields += "," + pf
+	}
+	var is []jira.Issue
+	for {
+		req, err := u.j.NewRequest("GET", fmt.Sprintf("/rest/agile/1.0/sprint/%s/issue?fields=%s&expand=changelog&startAt=%d", id, fields, len(is)), nil)
+		if err != nil {
+			u.err(err.Error())
+			return
+		}
+		var page struct {
+			Total  int          `json:"total"`
+			Issues []jira.Issue `json:"issues"`
+		}
+		if _, err := u.j.Do(req, &page); err != nil {
+			u.err(err.Error())
+			return
+		}
+		is = append(is, page.Issues...)
+		if len(page.Issues) == 0 || len(is) >= page.Total {
+			break
+		}
+	}
+
+	v := sprintView{sprint: s}
+	var total, doneTotal float64
+	for _, i := range is {
+		si := sprintIssue{
+			Key:     i.Key,
+			Type:    i.Fields.Type.Name,
+			Summary: i.Fields.Summary,
+			Points:  "-",
+		}
+		if i.Fields.Status != nil {
+			si.Status = i.Fields.Status.Name
+			si.was = done[i.Fields.Status.ID]
+		}
+		if p, ok := i.Fields.Unknowns[pf].(float64); ok {
+			si.points = p
+			si.Points = formatPoints(p)
+		}
+		if i.Changelog != nil {
+			for _, h := range i.Changelog.Histories {
+				at, err := time.Parse(jiraDateFmt, h.Created)
+				if err != nil {
+					debug("changelog %s: %v", i.Key, err)
+					continue
+				}
+				for _, it := range h.Items {
+					if it.Field != "status" {
+						continue
+					}
+					from, _ := it.From.(string)
+					to, _ := it.To.(string)
+					si.changes = append(si.changes, doneChange{At: at, Was: done[from], Done: done[to]})
+				}
+			}
+			sort.SliceStable(si.changes, func(a, b int) bool {
+				return si.changes[a].At.Before(si.changes[b].At)
+			})
+			if len(si.changes) != 0 {
+				si.was = si.changes[0].Was
+			}
+		}
+		total += si.points
+		if si.doneAt(time.Now()) {
+			doneTotal += si.points
+		}
+		v.Issues = append(v.Issues, si)
+	}
+	v.Total = formatPoints(total)
+	v.Done = formatPoints(doneTotal)
+	if s.StartDate != nil {
+		v.Start = s.StartDate.Local().Format(time.RFC1123)
+	}
+	if s.EndDate != nil {
+		v.End = s.EndDate.Local().Format(time.RFC1123)
+	}
+	if s.StartDate != nil && s.EndDate != nil {
+		end := *s.EndDate
+		if s.CompleteDate != nil {
+			end = *s.CompleteDate
+		}
+		v.Burndown = burndown(s.StartDate.Local(), end.Local(), time.Now(), v.Issues)
+	}
+
+	var buf bytes.Buffer
+	if err := u.tmpls.ExecuteTemplate(&buf, "sprint", &v); err != nil {
+		u.err(err.Error())
+		return
+	}
+	w.Clear()
+	w.Write("data", buf.Bytes())
+	w.Ctl("clean")
+	w.Addr("0")
+	w.Ctl("dot=addr")
+	w.Ctl("show")
+}
+
+// sprintCmd handles the commands of a sprint window:
+//
+//	Start [END]
+//	Complete
+//
+// END is the date (as YYYY-MM-DD) the sprint should end, if the sprint
+// doesn't already have one. Sprints are two weeks long otherwise.
+func (u *UI) sprintCmd(w *win, cmd string, e *acme.Event) bool {
+	args := strings.Fields(cmd)
+	if len(args) == 0 {
+		return false
+	}
+	args = append(args, strings.Fields(string(e.Arg))...)
+	id := strings.TrimPrefix(w.Title, "sprint/")
+	up := make(map[string]string)
+	switch args[0] {
+	case "Start":
+		s, err := u.getSprint(id)
+		if err != nil {
+			u.err(err.Error())
+			return true
+		}
+		now := time.Now()
+		start, end := now, now.AddDate(0, 0, 14)
+		if s.StartDate != nil {
+			start = *s.StartDate
+		}
+		if s.EndDate != nil {
+			end = *s.EndDate
+		}
+		if len(args) > 1 {
+			end, err = time.ParseInLocation("2006-01-02", args[1], time.Local)
+			if err != nil {
+				u.err(fmt.Sprintf("Start: bad end date: %v", err))
+				return true
+			}
+		}
+		up["state"] = "active"
+		up["startDate"] = start.Format(time.RFC3339)
+		up["endDate"] = end.Format(time.RFC3339)
+	case "Complete":
+		up["state"] = "closed"
+	default:
+		return false
+	}
+	debug("sprint %s update: %v", id, up)
+	req, err := u.j.NewRequest("POST", "/rest/agile/1.0/sprint/"+id, up)
+	if err != nil {
+		u.err(err.Error())
+		return true
+	}
+	if _, err := u.j.Do(req, nil); err != nil {
+		u.err(fmt.Sprintf("%s sprint: %v", args[0], err))
+	}
+	w.Reload()
+	return true
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBurndown(t *testing.T) {
+	day := func(d, h int) time.Time {
+		return time.Date(2022, time.May, d, h, 0, 0, 0, time.UTC)
+	}
+	is := []sprintIssue{
+		{points: 3, changes: []doneChange{{At: day(2, 10), Done: true}}},
+		{points: 5, changes: []doneChange{{At: day(3, 10), Done: true}, {At: day(3, 12), Was: true}}},
+		{points: 2, was: true},
+		{points: 1},
+	}
+	got := burndown(day(1, 9), day(5, 17), day(4, 12), is)
+	want := []string{"9", "6", "6", "6"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d days, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Remaining != want[i] {
+			t.Errorf("%s: got %q, want %q", got[i].Day, got[i].Remaining, want[i])
+		}
+		t.Logf("%s\t%s\t%s", got[i].Day, got[i].Remaining, got[i].Bar)
+	}
+}
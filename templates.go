@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+// overrideTemplates returns the templates with any in dir replacing the
+// built-in ones of the same name. Files that fail to parse are reported and
+// skipped, leaving the built-in template in place.
+func overrideTemplates(base *template.Template, dir string) (*template.Template, []error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return base, []error{fmt.Errorf("templates: %w", err)}
+	}
+	t, err := base.Clone()
+	if err != nil {
+		return base, []error{fmt.Errorf("templates: %w", err)}
+	}
+	var errs []error
+	for _, e := range ents {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+			continue
+		}
+		fn := filepath.Join(dir, name)
+		b, err := os.ReadFile(fn)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// Check it on its own first, so a broken file can't leave half of
+		// itself behind.
+		if _, err := template.New(name).Funcs(tmplFuncs).Parse(string(b)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", fn, err))
+			continue
+		}
+		if base.Lookup(name) == nil {
+			debug("template %q doesn't override anything", name)
+		}
+		if _, err := t.New(name).Parse(string(b)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", fn, err))
+			continue
+		}
+		debug("using template %s", fn)
+	}
+	return t, errs
+}
+
+// relTime describes t relative to now, like "3h ago" or "in 2d".
+func relTime(t, now time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	d := now.Sub(t)
+	f := "%s ago"
+	if d < 0 {
+		d, f = -d, "in %s"
+	}
+	var s string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		s = fmt.Sprintf("%dm", d/time.Minute)
+	case d < 24*time.Hour:
+		s = fmt.Sprintf("%dh", d/time.Hour)
+	case d < 14*24*time.Hour:
+		s = fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d < 60*24*time.Hour:
+		s = fmt.Sprintf("%dw", d/(7*24*time.Hour))
+	case d < 365*24*time.Hour:
+		s = fmt.Sprintf("%dmo", d/(30*24*time.Hour))
+	default:
+		s = fmt.Sprintf("%dy", d/(365*24*time.Hour))
+	}
+	return fmt.Sprintf(f, s)
+}
+
+// toTime turns the kinds of times found in issues into a time.Time.
+func toTime(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, nil
+		}
+		return *v, nil
+	case jira.Time:
+		return time.Time(v), nil
+	case *jira.Time:
+		if v == nil {
+			return time.Time{}, nil
+		}
+		return time.Time(*v), nil
+	case jira.Date:
+		return time.Time(v), nil
+	case string:
+		if v == "" {
+			return time.Time{}, nil
+		}
+		return time.Parse(jiraDateFmt, v)
+	}
+	return time.Time{}, fmt.Errorf("can't make a time out of %T", v)
+}
+
+// workDuration formats seconds of logged work the way Jira does, with 8 hour
+// days and 5 day weeks: "1w 2d 4h 30m".
+func workDuration(secs int) string {
+	if secs <= 0 {
+		return "0m"
+	}
+	m := secs / 60
+	var s []string
+	for _, u := range []struct {
+		n    int
+		unit string
+	}{
+		{5 * 8 * 60, "w"},
+		{8 * 60, "d"},
+		{60, "h"},
+		{1, "m"},
+	} {
+		if m >= u.n {
+			s = append(s, fmt.Sprintf("%d%s", m/u.n, u.unit))
+			m %= u.n
+		}
+	}
+	if len(s) == 0 {
+		return fmt.Sprintf("%ds", secs)
+	}
+	return strings.Join(s, " ")
+}
+
+// truncate cuts s down to n runes, marking where it was cut.
+func truncate(n int, s string) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+// pad pads s with spaces to n runes wide, on the left if n is negative.
+func pad(n int, s string) string {
+	l := utf8.RuneCountInString(s)
+	switch {
+	case n < 0 && l < -n:
+		return strings.Repeat(" ", -n-l) + s
+	case n > 0 && l < n:
+		return s + strings.Repeat(" ", n-l)
+	}
+	return s
+}
+
+// tmplField is the "field" function of a UI's templates, which can look up
+// fields by name as well as ID.
+func (u *UI) tmplField(i *jira.Issue, name string) string {
+	id := u.fieldID(name)
+	if id == "" {
+		id = name
+	}
+	return fieldString(i, id)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestOverrideTemplates(t *testing.T) {
+	base := template.Must(template.New("").Funcs(tmplFuncs).Parse(
+		`{{define "issues"}}built-in issues{{end}}{{define "headers"}}built-in headers{{end}}`))
+	dir := t.TempDir()
+	for name, s := range map[string]string{
+		"issues":  `{{range .}}{{.}} {{end}}`,
+		"headers": `{{if}}`,
+		".swp":    `{{`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tm, errs := overrideTemplates(base, dir)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "headers") {
+		t.Errorf("got errors %v, want one for headers", errs)
+	}
+	exec := func(tm *template.Template, name string, data interface{}) string {
+		var buf bytes.Buffer
+		if err := tm.ExecuteTemplate(&buf, name, data); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	if got := exec(tm, "issues", []string{"A-1", "A-2"}); got != "A-1 A-2 " {
+		t.Errorf("issues: got %q", got)
+	}
+	if got := exec(tm, "headers", nil); got != "built-in headers" {
+		t.Errorf("headers: got %q", got)
+	}
+	if got := exec(base, "issues", nil); got != "built-in issues" {
+		t.Errorf("base issues: got %q", got)
+	}
+}
+
+func TestRelTime(t *testing.T) {
+	now := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+	tt := []struct {
+		d    time.Duration
+		want string
+	}{
+		{10 * time.Second, "just now"},
+		{5 * time.Minute, "5m ago"},
+		{3*time.Hour + 59*time.Minute, "3h ago"},
+		{50 * time.Hour, "2d ago"},
+		{20 * 24 * time.Hour, "2w ago"},
+		{100 * 24 * time.Hour, "3mo ago"},
+		{800 * 24 * time.Hour, "2y ago"},
+		{-2 * time.Hour, "in 2h"},
+	}
+	for _, tc := range tt {
+		if got := relTime(now.Add(-tc.d), now); got != tc.want {
+			t.Errorf("%v: got %q, want %q", tc.d, got, tc.want)
+		}
+	}
+	if got := relTime(time.Time{}, now); got != "" {
+		t.Errorf("zero time: got %q", got)
+	}
+}
+
+func TestWorkDuration(t *testing.T) {
+	tt := []struct {
+		secs int
+		want string
+	}{
+		{0, "0m"},
+		{30, "30s"},
+		{90 * 60, "1h 30m"},
+		{8 * 3600, "1d"},
+		{(5*8+2*8+4)*3600 + 30*60, "1w 2d 4h 30m"},
+	}
+	for _, tc := range tt {
+		if got := workDuration(tc.secs); got != tc.want {
+			t.Errorf("%d: got %q, want %q", tc.secs, got, tc.want)
+		}
+	}
+}
+
+func TestTruncatePad(t *testing.T) {
+	if got := truncate(5, "résumé writing"); got != "résu…" {
+		t.Errorf("truncate: got %q", got)
+	}
+	if got := truncate(20, "short"); got != "short" {
+		t.Errorf("truncate: got %q", got)
+	}
+	if got := pad(6, "né"); got != "né    " {
+		t.Errorf("pad: got %q", got)
+	}
+	if got := pad(-6, "42"); got != "    42" {
+		t.Errorf("pad: got %q", got)
+	}
+	if got := pad(2, "toolong"); got != "toolong" {
+		t.Errorf("pad: got %q", got)
+	}
+}
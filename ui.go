@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"text/template"
 
 	"9fans.net/go/acme"
 	"9fans.net/go/plan9/client"
@@ -15,10 +16,14 @@ import (
 	jira "github.com/andygrunwald/go-jira"
 )
 
-const (
-	addrdelim = "/[! \t\\n<>()\\[\\]\"']/"
-	myIssues  = `assignee = currentUser() AND resolution = Unresolved order by updated desc`
-)
+const addrdelim = "/[! \t\\n<>()\\[\\]\"']/"
+
+// HomeQueries are the queries that get a window of their own, named for the
+// query. The "mine" query is shown in the my-issues window.
+var homeQueries = map[string]string{
+	"mine":           `assignee = currentUser() AND resolution = Unresolved order by updated desc`,
+	"reported-by-me": `reporter = currentUser() AND resolution = Unresolved order by updated desc`,
+}
 
 type win struct {
 	*acme.Win
@@ -34,6 +39,25 @@ type win struct {
 	headers    *headers
 
 	Search bool
+
+	// If a list of search results, these should exist.
+	jql    string
+	fields []string
+	head   int // lines of header above the count of results
+	issues []jira.Issue
+	total  int
+	group  string
+
+	// If the filters window, the filters as last fetched.
+	filters []*jira.Filter
+
+	// If a backlog window, the issue keys in rank order.
+	rank []string
+
+	// Windows with commands of their own handle them in exec, which reports
+	// whether the event was consumed. Look is the same, for button 3.
+	exec func(w *win, cmd string, e *acme.Event) bool
+	look func(w *win, e *acme.Event) bool
 }
 
 func (w *win) Clear() {
@@ -93,6 +117,9 @@ Event:
 		switch e.C2 {
 		case 'x', 'X': // button 2
 			cmd := strings.TrimSpace(string(e.Text))
+			if w.exec != nil && w.exec(w, cmd, e) {
+				continue
+			}
 			switch cmd {
 			case "Put":
 				w.Put()
@@ -111,19 +138,39 @@ Event:
 					w.Ctl("mark")
 					w.Ctl("clean")
 					eol(w, 1)
+					w.jql, w.issues, w.total = "", nil, 0
 					continue
 				}
-			case "Search":
-				ui.look("search")
-				w := ui.show("search")
-				if len(e.Arg) != 0 {
-					w.Ctl("nomark")
-					w.Addr(`1`)
-					w.Fprintf("data", "Search %s\n", jqlSan.Replace(string(e.Arg)))
-					w.Ctl("mark")
+			case "SaveFilter":
+				if w.Search {
+					ui.saveFilter(w, strings.TrimSpace(string(e.Arg)))
+					continue
+				}
+			case "Export":
+				if w.jql != "" {
+					ui.export(w, strings.Fields(string(e.Arg)))
+					continue
+				}
+			case "Group":
+				if w.jql != "" {
+					ui.regroup(w, string(e.Arg))
+					continue
+				}
+			case "History":
+				if w.Search {
+					ui.history(w)
+					continue
+				}
+			case "Complete":
+				if w.Search {
+					ui.complete(w)
+					continue
+				}
+			case "More", "All":
+				if w.jql != "" {
+					ui.more(w, cmd == "All")
+					continue
 				}
-				w.Reload()
-				continue
 			}
 			if w.Issue {
 				if id, ok := w.tr[cmd]; ok {
@@ -133,42 +180,39 @@ Event:
 					continue
 				}
 			}
+			if strings.HasPrefix(cmd, "Search") {
+				ui.searchCmd(cmd, string(e.Arg))
+				continue
+			}
 			arg0, argv, ok := strings.Cut(cmd, " ")
 			if !ok {
 				break
 			}
 			switch arg0 {
+			case "Group":
+				if w.jql != "" {
+					ui.regroup(w, argv+" "+string(e.Arg))
+					continue
+				}
+			case "Export":
+				if w.jql != "" {
+					ui.export(w, strings.Fields(argv+" "+string(e.Arg)))
+					continue
+				}
+			case "SaveFilter":
+				if w.Search {
+					ui.saveFilter(w, strings.TrimSpace(argv+" "+string(e.Arg)))
+					continue
+				}
 			case "New":
 				//ui.issueTemplate(string(e.Arg))
 				ui.err("need to improve the issue template")
 				continue
-			case "Search":
-				ui.look("search")
-				w := ui.show("search")
-				w.Ctl("nomark")
-				w.Addr(`1`)
-				w.Fprintf("data", "Search %s %s\n", jqlSan.Replace(argv), jqlSan.Replace(string(e.Arg)))
-				w.Ctl("mark")
-				w.Reload()
-				continue
 			}
-			if strings.HasPrefix(cmd, "Search") {
-				ui.look("search")
-				w := ui.show("search")
-				if len(cmd) != 6 {
-					w.Ctl("nomark")
-					w.Addr(`1`)
-					w.Fprintf("data", "%s", cmd)
-					if len(e.Arg) != 0 {
-						w.Fprintf("data", " %s", string(e.Arg))
-					}
-					w.Fprintf("data", "\n")
-					w.Ctl("mark")
-				}
-				w.Reload()
+		case 'l', 'L': // button 3
+			if w.look != nil && w.look(w, e) {
 				continue
 			}
-		case 'l', 'L': // button 3
 			if ui.look(string(e.Text)) {
 				// we found it, or made it!
 				continue
@@ -197,14 +241,7 @@ Event:
 						continue
 					}
 					debug("found %q: id %s", string(e.Text), a.ID)
-					base := ui.j.GetBaseURL()
-					rel, err := url.Parse(path.Join("secure", "attachment", a.ID))
-					if err != nil {
-						ui.err(err.Error())
-						continue
-					}
-					rel.Path = strings.TrimLeft(rel.Path, "/")
-					tgt := base.ResolveReference(rel).String() + "/"
+					tgt := ui.siteURL("secure", "attachment", a.ID) + "/"
 					debug("plumbing %q", tgt)
 					if ui.plumb == nil {
 						continue
@@ -225,6 +262,22 @@ Event:
 	}
 }
 
+// dotKey returns the issue key for the line under the cursor.
+func (w *win) dotKey(re *regexp.Regexp) string {
+	w.Ctl("addr=dot")
+	q0, _, err := w.ReadAddr()
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	b, err := w.ReadAll("body")
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	return keyAt(b, q0, re)
+}
+
 func getFilename(b []byte) string {
 	b = bytes.TrimLeft(b, "^")
 	b, _, _ = bytes.Cut(b, []byte("|"))
@@ -255,15 +308,33 @@ func (w *win) comment() string {
 
 type UI struct {
 	sync.Mutex
-	win    map[string]*win
+	win map[string]*win
+	// Exited is closed once the UI gives up on the server. Closing the last
+	// window doesn't do that: the server can still be brought back by the
+	// plumber.
 	exited chan struct{}
+	// ExitOnce guards closing exited, as the UI can give up on its own and
+	// be told to leave.
+	exitOnce sync.Once
 
 	j      *jira.Client
 	prefix string
+	// Site is the URL users visit. It's not always where the API is: with
+	// OAuth, that's on api.atlassian.com.
+	site *url.URL
+	// Queries are the home queries, and names maps field names to IDs
+	// ahead of what the server says.
+	queries map[string]string
+	names   map[string]string
+	// Tmpls are the templates, with functions that need the UI.
+	tmpls *template.Template
 
 	types   map[string]*jira.IssueType
 	typesMu *sync.Mutex
 
+	fields   []jira.Field
+	fieldsMu *sync.Mutex
+
 	proj   jira.ProjectList
 	projMu *sync.Mutex
 	projRe *regexp.Regexp
@@ -285,19 +356,34 @@ func (u *UI) err(s string) {
 	w.Ctl("show")
 }
 
-func New(prefix string, j *jira.Client) (*UI, error) {
+func New(prefix string, site *url.URL, j *jira.Client) (*UI, error) {
 	prefix = path.Join("/jira", prefix)
 	u := &UI{
 		j:      j,
 		prefix: prefix,
+		site:   site,
 
-		typesMu: &sync.Mutex{},
-		projMu:  &sync.Mutex{},
+		typesMu:  &sync.Mutex{},
+		fieldsMu: &sync.Mutex{},
+		projMu:   &sync.Mutex{},
 
-		types:  make(map[string]*jira.IssueType),
-		win:    make(map[string]*win),
-		exited: make(chan struct{}),
+		types:   make(map[string]*jira.IssueType),
+		win:     make(map[string]*win),
+		exited:  make(chan struct{}),
+		queries: make(map[string]string),
+		names:   make(map[string]string),
+	}
+	for n, q := range homeQueries {
+		u.queries[n] = q
+	}
+	t, err := tmpls.Clone()
+	if err != nil {
+		return nil, err
 	}
+	u.tmpls = t.Funcs(map[string]any{
+		"field":     u.tmplField,
+		"issuelink": u.issueLink,
+	})
 	pc, err := plumb.Open("send", 1) // WRONLY
 	if err != nil {
 		log.Printf("unable to open connection to plumber: %v", err)
@@ -308,10 +394,23 @@ func New(prefix string, j *jira.Client) (*UI, error) {
 	return u, nil
 }
 
+// siteURL returns the URL of a page on the site, for the user to visit.
+func (u *UI) siteURL(elem ...string) string {
+	r := *u.site
+	r.Path = path.Join(append([]string{"/", r.Path}, elem...)...)
+	r.RawPath = ""
+	return r.String()
+}
+
+// issueLink returns the URL of the issue's page on the site.
+func (u *UI) issueLink(i *jira.Issue) string {
+	return u.siteURL("browse", i.Key)
+}
+
 func (u *UI) updateCaches() {
 	// TODO(hank) figure out best time to refresh these
 	var wg sync.WaitGroup
-	wg.Add(2)
+	wg.Add(3)
 
 	go func() {
 		defer wg.Done()
@@ -333,13 +432,25 @@ func (u *UI) updateCaches() {
 		}
 	}()
 
+	go func() {
+		defer wg.Done()
+		u.fieldsMu.Lock()
+		defer u.fieldsMu.Unlock()
+		l, _, err := u.j.Field.GetList()
+		if err != nil {
+			u.err(err.Error())
+			return
+		}
+		u.fields = l
+	}()
+
 	go func() {
 		defer wg.Done()
 		u.projMu.Lock()
 		defer u.projMu.Unlock()
 		l, _, err := u.j.Project.GetList()
 		if err != nil {
-			close(u.exited)
+			u.done()
 			return
 		}
 		var r []string
@@ -353,6 +464,30 @@ func (u *UI) updateCaches() {
 	wg.Wait()
 }
 
+// fieldID returns the ID of the field known by the ID, name, or JQL clause
+// name f, or the empty string if there is no such field. Names from the
+// configuration file come first.
+func (u *UI) fieldID(f string) string {
+	for n, id := range u.names {
+		if strings.EqualFold(n, f) {
+			return id
+		}
+	}
+	u.fieldsMu.Lock()
+	defer u.fieldsMu.Unlock()
+	for _, fd := range u.fields {
+		if fd.ID == f || strings.EqualFold(fd.Name, f) {
+			return fd.ID
+		}
+		for _, n := range fd.ClauseNames {
+			if strings.EqualFold(n, f) {
+				return fd.ID
+			}
+		}
+	}
+	return ""
+}
+
 func (u *UI) new(title string) *win {
 	u.Lock()
 	defer u.Unlock()
@@ -393,17 +528,7 @@ func (u *UI) look(title string) bool {
 	debug("looking: %q\n", title)
 	switch title {
 	case "my-issues", "mine", "Mine", "", "/":
-		if w := u.show("my-issues"); w == nil {
-			w = u.new("my-issues")
-			if w == nil {
-				return false
-			}
-			w.Ctl("cleartag")
-			w.Fprintf("tag", " Get New Filters Search ")
-			w.reload = u.fetchMine
-			w.reload(w)
-		}
-		return true
+		return u.lookHome("my-issues", u.queries["mine"])
 	case "new-issue":
 		if w := u.show("new-issue"); w == nil {
 			if w = u.issueTemplate(); w == nil {
@@ -412,21 +537,7 @@ func (u *UI) look(title string) bool {
 		}
 		return true
 	case "Search", "search":
-		if w := u.show("search"); w == nil {
-			w = u.new("search")
-			if w == nil {
-				return false
-			}
-			w.Ctl("cleartag")
-			w.Fprintf("tag", " Get Clear ")
-			w.Fprintf("data", "Search %s\n", myIssues)
-			eol(w, 1)
-			w.Ctl("mark")
-			w.Ctl("clean")
-			w.Search = true
-			w.reload = u.search
-		}
-		return true
+		return u.lookSearch("search")
 	case "Filters", "filters":
 		w := u.show("filters")
 		if w != nil {
@@ -437,11 +548,60 @@ func (u *UI) look(title string) bool {
 			return false
 		}
 		w.Ctl("cleartag")
-		w.Fprintf("tag", " Get Search ")
+		w.Fprintf("tag", " Get Put Search ")
 		w.reload = u.fetchFilters
+		w.put = u.putFilters
+		w.look = u.lookFilter
 		w.reload(w)
 		return true
 	}
+	if q, ok := u.queries[title]; ok {
+		return u.lookHome(title, q)
+	}
+	switch kind, _, _ := strings.Cut(title, "/"); kind {
+	case "search":
+		return u.lookSearch(title)
+	case "filter":
+		if w := u.show(title); w == nil {
+			w = u.new(title)
+			if w == nil {
+				return false
+			}
+			w.Ctl("cleartag")
+			w.Fprintf("tag", " Get More All ")
+			w.head = 1
+			w.fields = splitFields(*listFields)
+			w.reload = u.fetchFilter
+			w.reload(w)
+		}
+		return true
+	case "backlog":
+		if w := u.show(title); w == nil {
+			w = u.new(title)
+			if w == nil {
+				return false
+			}
+			w.Ctl("cleartag")
+			w.Fprintf("tag", " Get Top Bottom Rank ")
+			w.reload = u.fetchBacklog
+			w.exec = u.rankCmd
+			w.reload(w)
+		}
+		return true
+	case "sprint":
+		if w := u.show(title); w == nil {
+			w = u.new(title)
+			if w == nil {
+				return false
+			}
+			w.Ctl("cleartag")
+			w.Fprintf("tag", " Get Start Complete ")
+			w.reload = u.fetchSprint
+			w.exec = u.sprintCmd
+			w.reload(w)
+		}
+		return true
+	}
 	if u.projRe.MatchString(title) {
 		if w := u.show(title); w == nil {
 			// open the issue
@@ -459,25 +619,22 @@ func (u *UI) look(title string) bool {
 	return false
 }
 
-func (u *UI) fetchMine(w *win) {
-	l, _, err := u.j.Issue.Search(myIssues, nil)
-	if err != nil {
-		u.err(err.Error())
-		return
+// lookHome is show-or-create for the windows of home queries.
+func (u *UI) lookHome(title, q string) bool {
+	if w := u.show(title); w != nil {
+		return true
 	}
-
-	w.Clear()
-	var buf bytes.Buffer
-	if err := tmpls.ExecuteTemplate(&buf, "issues", l); err != nil {
-		u.err(err.Error())
-		return
+	w := u.new(title)
+	if w == nil {
+		return false
 	}
-
-	w.Write("data", buf.Bytes())
-	w.Ctl("clean")
-	w.Addr("0")
-	w.Ctl("dot=addr")
-	w.Ctl("show")
+	w.Ctl("cleartag")
+	w.Fprintf("tag", " Get New Filters Search More All ")
+	w.jql = q
+	w.fields = splitFields(*listFields)
+	w.reload = u.fetchList
+	w.reload(w)
+	return true
 }
 
 func (u *UI) fetchFilters(w *win) {
@@ -489,10 +646,11 @@ func (u *UI) fetchFilters(w *win) {
 
 	w.Clear()
 	var buf bytes.Buffer
-	if err := tmpls.ExecuteTemplate(&buf, "filters", fs); err != nil {
+	if err := u.tmpls.ExecuteTemplate(&buf, "filters", fs); err != nil {
 		u.err(err.Error())
 		return
 	}
+	w.filters = fs
 	w.Write("data", buf.Bytes())
 	w.Ctl("clean")
 	w.Addr("0")
@@ -504,9 +662,13 @@ func (u *UI) exit(title string) {
 	u.Lock()
 	defer u.Unlock()
 	delete(u.win, title)
-	if len(u.win) == 0 {
-		close(u.exited)
-	}
+	poke()
+}
+
+// done marks the UI as exited.
+func (u *UI) done() {
+	u.exitOnce.Do(func() { close(u.exited) })
+	poke()
 }
 
 func (u *UI) rename(old, new string) {
@@ -528,5 +690,5 @@ func (u *UI) leave() {
 		delete(u.win, title)
 		w.Del(true)
 	}
-	close(u.exited)
+	u.done()
 }
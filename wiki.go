@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// WikiLine is a line of rendered wiki markup. Indent is what continuation
+// lines get if the line needs wrapping, and raw lines don't get wrapped.
+type wikiLine struct {
+	Text   string
+	Indent string
+	Raw    bool
+}
+
+var (
+	wikiHeading = regexp.MustCompile(`^h([1-6])\.\s+(.*)$`)
+	wikiList    = regexp.MustCompile(`^([*#-]+)\s+(.*)$`)
+	wikiRule    = regexp.MustCompile(`^-{4,}\s*$`)
+	wikiBlock   = regexp.MustCompile(`\{(code|noformat|quote|panel)(?::([^}]*))?\}`)
+	// An image can't start in the middle of a word: "Wow!Really!" isn't one.
+	wikiToken   = regexp.MustCompile(`\{\{(.*?)\}\}|\[([^\[\]]*)\]|\B!([^!\s|]+)(?:\|[^!\n]*)?!|[a-z][a-z0-9+.-]*://[^\s<>\[\]|]+`)
+	wikiTag     = regexp.MustCompile(`\{(?:color|anchor)(?::[^}]*)?\}`)
+	wikiHold    = regexp.MustCompile("\uE100([0-9]+)\uE101")
+	wikiEffects []*regexp.Regexp
+)
+
+func init() {
+	// Bold, italic, underline, strikethrough, and citation. The markers
+	// have to hug the text, and not be in the middle of a word.
+	for _, m := range []string{`\*`, `_`, `\+`, `-`, `\?\?`} {
+		wikiEffects = append(wikiEffects, regexp.MustCompile(
+			`(^|[^\p{L}\p{N}])`+m+`([^\s`+m+`](?:[^`+m+`]*[^\s`+m+`])?)`+m+`($|[^\p{L}\p{N}])`))
+	}
+}
+
+// wiki renders Jira wiki markup as plain text, then wraps it the same as
+// wrap. Code and noformat blocks are left as they are, and tables are lined
+// up. The rendering is only for reading: Put never sends descriptions or
+// existing comments back, so the markup on the server is left alone.
+func wiki(t, prefix string) string {
+	var out strings.Builder
+	for _, l := range renderWiki(t) {
+		if l.Raw || l.Text == "" {
+			out.WriteString(prefix)
+			out.WriteString(l.Text)
+			out.WriteByte('\n')
+			continue
+		}
+		wrapLine(&out, []byte(l.Text), prefix, l.Indent, *wrapWidth)
+	}
+	return out.String()
+}
+
+// renderWiki turns the block structure of the markup into lines.
+func renderWiki(t string) []wikiLine {
+	var r []wikiLine
+	emit := func(ls ...wikiLine) {
+		for _, l := range ls {
+			// Collapse runs of blank lines, and don't start with one.
+			if l.Text == "" && !l.Raw && (len(r) == 0 || r[len(r)-1].Text == "") {
+				continue
+			}
+			r = append(r, l)
+		}
+	}
+	var (
+		raw    string // the kind of block being copied as-is, if any
+		quote  bool
+		counts []int
+	)
+	text := func(s string) {
+		qp := ""
+		if quote {
+			qp = "> "
+		}
+		for _, l := range strings.Split(s, `\\`) {
+			emit(renderWikiLine(strings.TrimSpace(l), qp, &counts)...)
+		}
+	}
+	// Table rows are collected until the table ends, so the columns can be
+	// lined up.
+	var table [][]wikiCell
+	flush := func() {
+		if len(table) == 0 {
+			return
+		}
+		qp := ""
+		if quote {
+			qp = "> "
+		}
+		emit(wikiLine{})
+		emit(renderTable(table, qp, *wrapWidth)...)
+		emit(wikiLine{})
+		table = nil
+	}
+	s := bufio.NewScanner(strings.NewReader(strings.TrimSpace(t)))
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		if raw == "" {
+			if l := strings.TrimSpace(line); strings.HasPrefix(l, "|") && !wikiBlock.MatchString(l) {
+				table = append(table, parseWikiRow(l))
+				continue
+			}
+			flush()
+		}
+		if raw != "" {
+			end := "{" + raw + "}"
+			i := strings.Index(line, end)
+			if i == -1 {
+				emit(wikiLine{Text: line, Raw: true})
+				continue
+			}
+			if pre := line[:i]; strings.TrimSpace(pre) != "" {
+				emit(wikiLine{Text: pre, Raw: true})
+			}
+			raw = ""
+			if line = line[i+len(end):]; strings.TrimSpace(line) == "" {
+				continue
+			}
+		}
+
+		// Blocks can start and end in the middle of lines, so go through
+		// the line a piece at a time.
+		for {
+			m := wikiBlock.FindStringSubmatchIndex(line)
+			if m == nil {
+				text(line)
+				break
+			}
+			if pre := line[:m[0]]; strings.TrimSpace(pre) != "" {
+				text(pre)
+			}
+			kind := line[m[2]:m[3]]
+			var param string
+			if m[4] != -1 {
+				param = line[m[4]:m[5]]
+			}
+			line = line[m[1]:]
+			counts = counts[:0]
+			switch kind {
+			case "code", "noformat":
+				end := "{" + kind + "}"
+				if i := strings.Index(line, end); i != -1 {
+					emit(wikiLine{Text: line[:i], Raw: true})
+					line = line[i+len(end):]
+					break
+				}
+				if strings.TrimSpace(line) != "" {
+					emit(wikiLine{Text: line, Raw: true})
+				}
+				raw, line = kind, ""
+			case "quote":
+				quote = !quote
+			case "panel":
+				if title := wikiParam(param, "title"); title != "" {
+					emit(wikiLine{})
+					emit(underline(renderWikiInline(title), "-")...)
+				}
+			}
+			if strings.TrimSpace(line) == "" {
+				break
+			}
+		}
+	}
+	flush()
+	for len(r) != 0 && r[len(r)-1].Text == "" {
+		r = r[:len(r)-1]
+	}
+	return r
+}
+
+// renderWikiLine renders a line outside of any raw block, with qp before
+// it if it's quoted. Counts keeps track of the numbers of numbered lists.
+func renderWikiLine(line, qp string, counts *[]int) []wikiLine {
+	if line == "" {
+		*counts = (*counts)[:0]
+		return []wikiLine{{Text: strings.TrimSpace(qp)}}
+	}
+	if strings.HasPrefix(line, "bq. ") {
+		qp += "> "
+		line = strings.TrimSpace(line[len("bq. "):])
+	}
+	if m := wikiList.FindStringSubmatch(line); m != nil && !wikiRule.MatchString(line) {
+		depth := len(m[1])
+		c := *counts
+		for len(c) < depth {
+			c = append(c, 0)
+		}
+		c = c[:depth]
+		// Counts are kept negative for bulleted lists, so switching between
+		// the kinds starts over.
+		numbered := m[1][depth-1] == '#'
+		switch {
+		case numbered && c[depth-1] < 0, !numbered && c[depth-1] > 0:
+			c[depth-1] = 0
+		}
+		marker := "•"
+		if numbered {
+			c[depth-1]++
+			marker = strconv.Itoa(c[depth-1]) + "."
+		} else {
+			c[depth-1]--
+		}
+		*counts = c
+		lead := strings.Repeat("  ", depth-1) + marker + " "
+		return []wikiLine{{
+			Text:   qp + lead + renderWikiInline(m[2]),
+			Indent: qp + strings.Repeat(" ", utf8.RuneCountInString(lead)),
+		}}
+	}
+	*counts = (*counts)[:0]
+	switch {
+	case wikiRule.MatchString(line):
+		return []wikiLine{{Text: qp + strings.Repeat("-", 20), Raw: true}}
+	case wikiHeading.MatchString(line):
+		m := wikiHeading.FindStringSubmatch(line)
+		u := "-"
+		if m[1] <= "2" {
+			u = "="
+		}
+		ls := underline(renderWikiInline(m[2]), u)
+		for i := range ls {
+			ls[i].Text = qp + ls[i].Text
+			ls[i].Indent = qp
+		}
+		return append([]wikiLine{{}}, ls...)
+	}
+	return []wikiLine{{Text: qp + renderWikiInline(line), Indent: qp}}
+}
+
+func underline(s, u string) []wikiLine {
+	n := utf8.RuneCountInString(s)
+	if n > *wrapWidth {
+		n = *wrapWidth
+	}
+	return []wikiLine{{Text: s}, {Text: strings.Repeat(u, n), Raw: true}}
+}
+
+// wikiParam returns the named parameter of a macro like "{panel:title=X|bgColor=Y}".
+func wikiParam(params, name string) string {
+	for _, p := range strings.Split(params, "|") {
+		if k, v, ok := strings.Cut(p, "="); ok && strings.TrimSpace(k) == name {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// renderWikiInline renders the markup within a line: text effects, links,
+// images, and the like. Links come out as "text <url>", and images and
+// attachments as "[^name]", so they can be plumbed.
+func renderWikiInline(s string) string {
+	// Escaped characters are tucked out of the way in the private use area
+	// until the end.
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && s[i+1] < utf8.RuneSelf && strings.IndexByte(`*_+-?!^~[]{}|\`, s[i+1]) != -1 {
+			b.WriteRune(0xE000 + rune(s[i+1]))
+			i++
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	s = b.String()
+	s = wikiTag.ReplaceAllString(s, "")
+
+	// Anything that shouldn't have text effects applied is held aside.
+	var held []string
+	s = wikiToken.ReplaceAllStringFunc(s, func(tok string) string {
+		held = append(held, renderWikiToken(tok))
+		return fmt.Sprintf("\uE100%d\uE101", len(held)-1)
+	})
+	for _, re := range wikiEffects {
+		for {
+			n := re.ReplaceAllString(s, "$1$2$3")
+			if n == s {
+				break
+			}
+			s = n
+		}
+	}
+	s = wikiHold.ReplaceAllStringFunc(s, func(h string) string {
+		i, _ := strconv.Atoi(wikiHold.FindStringSubmatch(h)[1])
+		return held[i]
+	})
+	return strings.Map(func(r rune) rune {
+		if r >= 0xE000 && r < 0xE000+utf8.RuneSelf {
+			return r - 0xE000
+		}
+		return r
+	}, s)
+}
+
+func renderWikiToken(tok string) string {
+	switch {
+	case strings.HasPrefix(tok, "{{"):
+		return tok[2 : len(tok)-2]
+	case strings.HasPrefix(tok, "["):
+		in := tok[1 : len(tok)-1]
+		text, tgt, ok := strings.Cut(in, "|")
+		if !ok {
+			tgt, text = in, ""
+		}
+		tgt, _, _ = strings.Cut(tgt, "|")
+		tgt = strings.TrimSpace(tgt)
+		switch {
+		case strings.HasPrefix(tgt, "^"):
+			tgt = "[" + tgt + "]"
+		case strings.HasPrefix(tgt, "~"):
+			tgt = "@" + tgt[1:]
+		case strings.HasPrefix(tgt, "#"):
+			tgt = tgt[1:]
+		case !strings.Contains(tgt, "://") && !strings.HasPrefix(tgt, "mailto:"):
+			// Not a link; Jira shows these as they are.
+			return tok
+		}
+		if text = strings.TrimSpace(text); text == "" || text == tgt {
+			return tgt
+		}
+		if strings.HasPrefix(tgt, "@") || strings.HasPrefix(tgt, "[") {
+			return text + " " + tgt
+		}
+		return text + " <" + tgt + ">"
+	case strings.HasPrefix(tok, "!"):
+		name := strings.TrimPrefix(tok, "!")
+		name, _, _ = strings.Cut(name, "|")
+		name = strings.TrimSuffix(name, "!")
+		if strings.Contains(name, "://") {
+			return name
+		}
+		return "[^" + name + "]"
+	}
+	return tok
+}
+
+type wikiCell struct {
+	Text string
+	Head bool
+}
+
+// parseWikiRow splits a table row into its cells. Cells are separated by "|",
+// or "||" for headings, except inside links and monospaced text.
+func parseWikiRow(l string) []wikiCell {
+	var r []wikiCell
+	var cur strings.Builder
+	head := false
+	depth := 0
+	end := func() {
+		r = append(r, wikiCell{Text: renderWikiInline(strings.TrimSpace(cur.String())), Head: head})
+		cur.Reset()
+	}
+	for i := 0; i < len(l); i++ {
+		c := l[i]
+		switch {
+		case c == '\\' && i+1 < len(l):
+			cur.WriteByte(c)
+			cur.WriteByte(l[i+1])
+			i++
+			continue
+		case c == '[' || strings.HasPrefix(l[i:], "{{"):
+			depth++
+		case (c == ']' || strings.HasPrefix(l[i:], "}}")) && depth > 0:
+			depth--
+		case c == '|' && depth == 0:
+			if i != 0 {
+				end()
+			}
+			head = strings.HasPrefix(l[i:], "||")
+			if head {
+				i++
+			}
+			continue
+		}
+		cur.WriteByte(c)
+	}
+	if strings.TrimSpace(cur.String()) != "" {
+		end()
+	}
+	return r
+}
+
+// renderTable lays out the rows as columns, fitting them into width by
+// wrapping the text of the widest columns. A rule goes under any row of
+// headings.
+func renderTable(rows [][]wikiCell, qp string, width int) []wikiLine {
+	const gap = 2
+	// Columns are never narrower than their longest word, so links stay
+	// whole. The table can end up too wide because of that.
+	var ws, mins []int
+	for _, row := range rows {
+		for i, c := range row {
+			if i == len(ws) {
+				ws = append(ws, 0)
+				mins = append(mins, 1)
+			}
+			if n := utf8.RuneCountInString(c.Text); n > ws[i] {
+				ws[i] = n
+			}
+			for _, w := range strings.Fields(c.Text) {
+				if n := utf8.RuneCountInString(w); n > mins[i] {
+					mins[i] = n
+				}
+			}
+		}
+	}
+	total := func() int {
+		n := utf8.RuneCountInString(qp) + gap*(len(ws)-1)
+		for _, w := range ws {
+			n += w
+		}
+		return n
+	}
+	for total() > width {
+		wide := -1
+		for i := range ws {
+			if ws[i] > mins[i] && (wide == -1 || ws[i] > ws[wide]) {
+				wide = i
+			}
+		}
+		if wide == -1 {
+			break
+		}
+		ws[wide]--
+	}
+
+	var r []wikiLine
+	line := func(cells []string) {
+		var b strings.Builder
+		b.WriteString(qp)
+		for i, c := range cells {
+			if i == len(cells)-1 {
+				b.WriteString(c)
+				break
+			}
+			b.WriteString(pad(ws[i]+gap, c))
+		}
+		r = append(r, wikiLine{Text: strings.TrimRight(b.String(), " "), Raw: true})
+	}
+	for _, row := range rows {
+		cols := make([][]string, len(ws))
+		height := 1
+		head := len(row) != 0
+		for i := range ws {
+			if i < len(row) {
+				cols[i] = wrapCell(row[i].Text, ws[i])
+				head = head && row[i].Head
+			}
+			if len(cols[i]) > height {
+				height = len(cols[i])
+			}
+		}
+		for j := 0; j < height; j++ {
+			cells := make([]string, len(ws))
+			for i := range cols {
+				if j < len(cols[i]) {
+					cells[i] = cols[i][j]
+				}
+			}
+			line(cells)
+		}
+		if head {
+			// Only under the header's own cells: other rows can have more.
+			cells := make([]string, len(row))
+			for i := range row {
+				cells[i] = strings.Repeat("-", ws[i])
+			}
+			line(cells)
+		}
+	}
+	return r
+}
+
+// wrapCell breaks s into lines of at most width runes, or of a single word
+// if that's longer.
+func wrapCell(s string, width int) []string {
+	var r []string
+	var cur []rune
+	for _, w := range strings.Fields(s) {
+		word := []rune(w)
+		if len(cur) != 0 && len(cur)+1+len(word) > width {
+			r = append(r, string(cur))
+			cur = nil
+		}
+		if len(cur) != 0 {
+			cur = append(cur, ' ')
+		}
+		cur = append(cur, word...)
+	}
+	if len(cur) != 0 || len(r) == 0 {
+		r = append(r, string(cur))
+	}
+	return r
+}
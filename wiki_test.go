@@ -0,0 +1,169 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderWikiInline(t *testing.T) {
+	tt := []struct {
+		in, want string
+	}{
+		{"*bold* and _italic_ and -gone- and +under+", "bold and italic and gone and under"},
+		{"*one* *two*", "one two"},
+		{"snake_case_name well-known 2020-01-02 a - b - c 2*3*4", "snake_case_name well-known 2020-01-02 a - b - c 2*3*4"},
+		{"[a link|https://example.com/a_b_c]", "a link <https://example.com/a_b_c>"},
+		{"[https://example.com/]", "https://example.com/"},
+		{"https://example.com/_x_ stays", "https://example.com/_x_ stays"},
+		{"ask [~alice] about [^log.txt]", "ask @alice about [^log.txt]"},
+		{"[the log|^log.txt]", "the log [^log.txt]"},
+		{"!screen.png|thumbnail! and !https://example.com/i.png!", "[^screen.png] and https://example.com/i.png"},
+		{"Wow!Really! no", "Wow!Really! no"},
+		{"(!screen.png!)", "([^screen.png])"},
+		{"{{mono_*x*_}}", "mono_*x*_"},
+		{`\*not bold\* \[not a link\]`, "*not bold* [not a link]"},
+		{"{color:red}red{color} text", "red text"},
+		{"[just brackets]", "[just brackets]"},
+	}
+	for _, tc := range tt {
+		if got := renderWikiInline(tc.in); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestWiki(t *testing.T) {
+	defer func(w int) { *wrapWidth = w }(*wrapWidth)
+	*wrapWidth = 40
+	in := `h2. Steps
+# Open the *thing*
+# Click {{Go}}, which takes a while before anything happens
+* and then
+
+{quote}
+It broke.
+{quote}
+{panel:title=Logs}
+see [^out.log]
+{panel}
+{code:go}
+if x {
+	return     // a long line that isn't going to be wrapped at all
+}
+{code}
+{noformat}a  b{noformat}
+||Step||Result||
+|1|ok|
+Done\\really.`
+	want := `	Steps
+	=====
+	1. Open the thing
+	2. Click Go, which takes a while before
+	   anything happens
+	• and then
+	
+	> It broke.
+	
+	Logs
+	----
+	see [^out.log]
+	if x {
+		return     // a long line that isn't going to be wrapped at all
+	}
+	a  b
+	
+	Step  Result
+	----  ------
+	1     ok
+	
+	Done
+	really.
+`
+	if got := wiki(in, "\t"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		gl, wl := strings.Split(got, "\n"), strings.Split(want, "\n")
+		for i := range gl {
+			if i < len(wl) && gl[i] != wl[i] {
+				t.Logf("first difference at line %d: %q != %q", i+1, gl[i], wl[i])
+				break
+			}
+		}
+	}
+}
+
+func TestParseWikiRow(t *testing.T) {
+	tt := []struct {
+		in   string
+		want []wikiCell
+	}{
+		{"||Key||Summary||", []wikiCell{{"Key", true}, {"Summary", true}}},
+		{"|ABC-1| *done* |", []wikiCell{{"ABC-1", false}, {"done", false}}},
+		{"||Row|cell", []wikiCell{{"Row", true}, {"cell", false}}},
+		{"|[docs|https://example.com]|{{a|b}}|a\\|b|", []wikiCell{{"docs <https://example.com>", false}, {"a|b", false}, {"a|b", false}}},
+	}
+	for _, tc := range tt {
+		got := parseWikiRow(tc.in)
+		if len(got) != len(tc.want) {
+			t.Errorf("%q: got %v, want %v", tc.in, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%q: got %v, want %v", tc.in, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestRenderTable(t *testing.T) {
+	rows := [][]wikiCell{
+		{{"Key", true}, {"Summary", true}},
+		{{"ABC-1", false}, {"Short", false}},
+		{{"ABC-22", false}, {"A longer summary that needs wrapping", false}},
+		{{"ABC-3", false}},
+	}
+	var got []string
+	for _, l := range renderTable(rows, "", 30) {
+		if !l.Raw {
+			t.Errorf("%q: table lines shouldn't be wrapped again", l.Text)
+		}
+		got = append(got, l.Text)
+	}
+	want := []string{
+		"Key     Summary",
+		"------  ----------------------",
+		"ABC-1   Short",
+		"ABC-22  A longer summary that",
+		"        needs wrapping",
+		"ABC-3",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for _, l := range got {
+		if n := len([]rune(l)); n > 30 {
+			t.Errorf("%q: %d wide, want at most 30", l, n)
+		}
+	}
+
+	// A word longer than the space is kept whole, rather than broken.
+	rows = [][]wikiCell{{{"https://example.com/a/very/long/link", false}}}
+	if l := renderTable(rows, "", 10); len(l) != 1 || l[0].Text != rows[0][0].Text {
+		t.Errorf("got %v", l)
+	}
+
+	// The rule under a header only goes as far as the header.
+	rows = [][]wikiCell{
+		{{"a", true}, {"b", true}},
+		{{"1", false}, {"2", false}, {"3", false}, {"4", false}},
+	}
+	got = nil
+	for _, l := range renderTable(rows, "", 80) {
+		got = append(got, l.Text)
+	}
+	want = []string{"a  b", "-  -", "1  2  3  4"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}